@@ -0,0 +1,180 @@
+// Package bulkhead реализует изоляцию "переборками": ограничение числа
+// одновременных вызовов зависимости с ограниченной очередью ожидания,
+// чтобы медленная зависимость не забирала все горутины сервиса.
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+var (
+	// ErrFull - заняты все слоты и все места в очереди
+	ErrFull = errors.New("bulkhead is full")
+	// ErrQueueTimeout - вызов не дождался свободного слота за QueueTimeout
+	ErrQueueTimeout = errors.New("bulkhead queue timeout")
+)
+
+// RejectedError возвращается, когда вызов отклонен без выполнения.
+// Err - ErrFull или ErrQueueTimeout.
+type RejectedError struct {
+	Err    error // Причина отклонения
+	Active int   // Сколько вызовов выполнялось в момент отклонения
+	Queued int   // Сколько вызовов ожидало в очереди в момент отклонения
+}
+
+// Error возвращает текст ошибки с загрузкой переборки
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%v (active: %d, queued: %d)", e.Err, e.Active, e.Queued)
+}
+
+// Unwrap возвращает причину отклонения
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// Config - параметры переборки
+type Config struct {
+	MaxConcurrent int           // Максимум одновременно выполняемых вызовов
+	MaxQueue      int           // Максимум вызовов, ожидающих слот. 0 - без очереди
+	QueueTimeout  time.Duration // Сколько вызов ждет в очереди. 0 - пока жив контекст
+	Clock         clock.Clock   // Источник времени, по умолчанию реальные часы
+}
+
+// Metrics - снимок состояния и счетчиков переборки
+type Metrics struct {
+	MaxConcurrent int    // Лимит одновременных вызовов
+	MaxQueue      int    // Лимит очереди
+	Active        int    // Выполняется сейчас
+	Queued        int    // Ожидает в очереди сейчас
+	Accepted      uint64 // Всего вызовов, получивших слот
+	Rejected      uint64 // Всего вызовов, отклоненных из-за заполненной очереди
+	TimedOut      uint64 // Всего вызовов, не дождавшихся слота
+}
+
+// Bulkhead ограничивает число одновременных вызовов.
+// Безопасен для конкурентного использования.
+type Bulkhead struct {
+	config Config
+	clock  clock.Clock
+	slots  chan struct{} // Семафор выполняемых вызовов
+	queue  chan struct{} // Семафор мест в очереди
+
+	accepted atomic.Uint64
+	rejected atomic.Uint64
+	timedOut atomic.Uint64
+}
+
+// New создает переборку
+func New(config Config) *Bulkhead {
+	if config.MaxConcurrent <= 0 {
+		panic("max concurrent must be greater than 0")
+	}
+	if config.MaxQueue < 0 {
+		panic("max queue must not be negative")
+	}
+
+	return &Bulkhead{
+		config: config,
+		clock:  clock.OrDefault(config.Clock),
+		slots:  make(chan struct{}, config.MaxConcurrent),
+		queue:  make(chan struct{}, config.MaxQueue),
+	}
+}
+
+// Execute выполняет fn, если удалось получить слот.
+// Если слотов нет, вызов ждет в очереди не дольше QueueTimeout.
+// При отказе возвращается *RejectedError, а fn не вызывается.
+func (b *Bulkhead) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := b.acquire(ctx); err != nil {
+		return err
+	}
+	defer b.release()
+
+	return fn(ctx)
+}
+
+// Do выполняет fn с результатом через переборку b
+func Do[T any](ctx context.Context, b *Bulkhead, fn func(ctx context.Context) (T, error)) (T, error) {
+	var res T
+	err := b.Execute(ctx, func(ctx context.Context) error {
+		var err error
+		res, err = fn(ctx)
+		return err
+	})
+	return res, err
+}
+
+// Metrics возвращает текущий снимок метрик
+func (b *Bulkhead) Metrics() Metrics {
+	return Metrics{
+		MaxConcurrent: b.config.MaxConcurrent,
+		MaxQueue:      b.config.MaxQueue,
+		Active:        len(b.slots),
+		Queued:        len(b.queue),
+		Accepted:      b.accepted.Load(),
+		Rejected:      b.rejected.Load(),
+		TimedOut:      b.timedOut.Load(),
+	}
+}
+
+// acquire занимает слот, при необходимости ожидая в очереди
+func (b *Bulkhead) acquire(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Быстрый путь - свободный слот есть
+	select {
+	case b.slots <- struct{}{}:
+		b.accepted.Add(1)
+		return nil
+	default:
+	}
+
+	// Занимаем место в очереди
+	select {
+	case b.queue <- struct{}{}:
+	default:
+		b.rejected.Add(1)
+		return b.rejectedError(ErrFull)
+	}
+	defer func() { <-b.queue }()
+
+	var timeout <-chan time.Time
+	if b.config.QueueTimeout > 0 {
+		timer := b.clock.NewTimer(b.config.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	// Ждем освобождения слота
+	select {
+	case b.slots <- struct{}{}:
+		b.accepted.Add(1)
+		return nil
+	case <-timeout:
+		b.timedOut.Add(1)
+		return b.rejectedError(ErrQueueTimeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release освобождает слот
+func (b *Bulkhead) release() {
+	<-b.slots
+}
+
+func (b *Bulkhead) rejectedError(err error) *RejectedError {
+	return &RejectedError{
+		Err:    err,
+		Active: len(b.slots),
+		Queued: len(b.queue),
+	}
+}
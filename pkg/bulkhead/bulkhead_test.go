@@ -0,0 +1,163 @@
+package bulkhead
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-utils/pkg/clock"
+	"go-utils/pkg/retry"
+)
+
+// occupy занимает n слотов переборки до закрытия release
+func occupy(t *testing.T, b *Bulkhead, n int) (release func()) {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	started := make(chan struct{}, n)
+	done := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Execute(context.Background(), func(ctx context.Context) error {
+				started <- struct{}{}
+				<-done
+				return nil
+			})
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-started
+	}
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func TestBulkhead_RejectsWhenFull(t *testing.T) {
+	b := New(Config{MaxConcurrent: 2})
+	release := occupy(t, b, 2)
+	defer release()
+
+	called := false
+	err := b.Execute(context.Background(), func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("expected RejectedError, got %v", err)
+	}
+	if !errors.Is(err, ErrFull) {
+		t.Errorf("expected ErrFull, got %v", err)
+	}
+	if rejected.Active != 2 {
+		t.Errorf("expected 2 active calls in error, got %d", rejected.Active)
+	}
+	if called {
+		t.Error("function should not be called when bulkhead is full")
+	}
+}
+
+func TestBulkhead_QueuedCallGetsSlot(t *testing.T) {
+	b := New(Config{MaxConcurrent: 1, MaxQueue: 1})
+	release := occupy(t, b, 1)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- b.Execute(context.Background(), func(ctx context.Context) error {
+			return nil
+		})
+	}()
+
+	// Дожидаемся, пока вызов встанет в очередь
+	for b.Metrics().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Очередь занята - следующий вызов отклоняется сразу
+	if err := b.Execute(context.Background(), func(ctx context.Context) error { return nil }); !errors.Is(err, ErrFull) {
+		t.Errorf("expected ErrFull when queue is full, got %v", err)
+	}
+
+	release()
+	if err := <-result; err != nil {
+		t.Errorf("queued call failed: %v", err)
+	}
+
+	m := b.Metrics()
+	if m.Accepted != 2 || m.Rejected != 1 || m.Active != 0 || m.Queued != 0 {
+		t.Errorf("unexpected metrics: %+v", m)
+	}
+}
+
+func TestBulkhead_QueueTimeout(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	b := New(Config{MaxConcurrent: 1, MaxQueue: 1, QueueTimeout: time.Second, Clock: fake})
+	release := occupy(t, b, 1)
+	defer release()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- b.Execute(context.Background(), func(ctx context.Context) error {
+			return nil
+		})
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+
+	err := <-result
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected ErrQueueTimeout, got %v", err)
+	}
+	if b.Metrics().TimedOut != 1 {
+		t.Errorf("expected 1 timed out call, got %d", b.Metrics().TimedOut)
+	}
+}
+
+func TestBulkhead_QueueRespectsContext(t *testing.T) {
+	b := New(Config{MaxConcurrent: 1, MaxQueue: 1})
+	release := occupy(t, b, 1)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := b.Execute(ctx, func(ctx context.Context) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestDo_InsideRetry(t *testing.T) {
+	b := New(Config{MaxConcurrent: 1})
+	config := retry.Config{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+	calls := 0
+	result, err := retry.Retry(context.Background(), config, func() (int, error) {
+		return Do(context.Background(), b, func(ctx context.Context) (int, error) {
+			calls++
+			if calls < 2 {
+				return 0, errors.New("temporary error")
+			}
+			return 42, nil
+		})
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != 42 || calls != 2 {
+		t.Errorf("expected 42 after 2 calls, got %d after %d calls", result, calls)
+	}
+	if b.Metrics().Active != 0 {
+		t.Errorf("slots were not released: %+v", b.Metrics())
+	}
+}
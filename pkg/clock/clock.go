@@ -0,0 +1,89 @@
+// Package clock предоставляет абстракцию над временем, чтобы код, зависящий
+// от задержек и таймеров, можно было тестировать без реального ожидания.
+// В рабочем коде используется New(), в тестах - NewFake().
+package clock
+
+import "time"
+
+// Clock - источник времени и таймеров
+type Clock interface {
+	// Now возвращает текущее время
+	Now() time.Time
+	// After возвращает канал, в который придет время по истечении d
+	After(d time.Duration) <-chan time.Time
+	// NewTimer создает таймер, срабатывающий один раз через d
+	NewTimer(d time.Duration) Timer
+	// NewTicker создает тикер, срабатывающий каждые d
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer - аналог *time.Timer
+type Timer interface {
+	// C возвращает канал срабатывания таймера
+	C() <-chan time.Time
+	// Stop останавливает таймер. Возвращает false, если таймер уже сработал или остановлен
+	Stop() bool
+	// Reset перезапускает таймер на d. Возвращает true, если таймер был активен
+	Reset(d time.Duration) bool
+}
+
+// Ticker - аналог *time.Ticker
+type Ticker interface {
+	// C возвращает канал тиков
+	C() <-chan time.Time
+	// Stop останавливает тикер
+	Stop()
+	// Reset меняет период тикера на d
+	Reset(d time.Duration)
+}
+
+// New возвращает Clock на основе стандартного пакета time
+func New() Clock {
+	return realClock{}
+}
+
+// OrDefault возвращает c, а если он nil - реальные часы.
+// Удобно для необязательных полей конфигурации.
+func OrDefault(c Clock) Clock {
+	if c == nil {
+		return New()
+	}
+	return c
+}
+
+// realClock - реализация Clock поверх пакета time
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{t: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{t: time.NewTicker(d)}
+}
+
+// realTimer оборачивает *time.Timer
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r *realTimer) C() <-chan time.Time        { return r.t.C }
+func (r *realTimer) Stop() bool                 { return r.t.Stop() }
+func (r *realTimer) Reset(d time.Duration) bool { return r.t.Reset(d) }
+
+// realTicker оборачивает *time.Ticker
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (r *realTicker) C() <-chan time.Time   { return r.t.C }
+func (r *realTicker) Stop()                 { r.t.Stop() }
+func (r *realTicker) Reset(d time.Duration) { r.t.Reset(d) }
@@ -0,0 +1,198 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake - часы, которые двигаются только вручную через Advance.
+// Таймеры и тикеры срабатывают синхронно внутри Advance, поэтому тесты
+// с задержками выполняются мгновенно и детерминированно.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter // Активные таймеры и тикеры
+}
+
+// fakeWaiter - общее представление таймера и тикера фейковых часов
+type fakeWaiter struct {
+	fake     *Fake
+	c        chan time.Time
+	deadline time.Time     // Момент следующего срабатывания
+	period   time.Duration // Период для тикера, 0 для таймера
+}
+
+// NewFake создает фейковые часы, показывающие время now
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now возвращает текущее время фейковых часов
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// After возвращает канал, в который придет время после Advance не меньше чем на d
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer создает таймер, срабатывающий после Advance не меньше чем на d
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	w := &fakeWaiter{fake: f, c: make(chan time.Time, 1)}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(w, d)
+	return fakeTimer{w}
+}
+
+// NewTicker создает тикер с периодом d
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{fake: f, c: make(chan time.Time, 1), period: d}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(w, d)
+	return fakeTicker{w}
+}
+
+// Advance сдвигает время на d и по порядку срабатывают все таймеры и тикеры,
+// чей момент наступил
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	target := f.now.Add(d)
+	for {
+		// Ищем ближайшее срабатывание, не позже целевого времени
+		sort.SliceStable(f.waiters, func(i, j int) bool {
+			return f.waiters[i].deadline.Before(f.waiters[j].deadline)
+		})
+		if len(f.waiters) == 0 || f.waiters[0].deadline.After(target) {
+			break
+		}
+
+		w := f.waiters[0]
+		f.now = w.deadline
+		w.fire(f.now)
+
+		if w.period > 0 {
+			// Тикер перезаводится на следующий период
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+	}
+	f.now = target
+}
+
+// BlockUntil блокируется, пока количество активных таймеров и тикеров
+// не станет не меньше n. Позволяет дождаться, когда тестируемый код
+// начнет ожидание, прежде чем двигать время.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// Waiters возвращает количество активных таймеров и тикеров
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// schedule ставит w на срабатывание через d. Вызывается под f.mu.
+func (f *Fake) schedule(w *fakeWaiter, d time.Duration) {
+	w.deadline = f.now.Add(d)
+	if d <= 0 && w.period == 0 {
+		// Как и time.Timer, таймер с неположительной задержкой срабатывает сразу
+		w.fire(f.now)
+		return
+	}
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+}
+
+// remove снимает w с ожидания. Вызывается под f.mu.
+func (f *Fake) remove(w *fakeWaiter) bool {
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fire отправляет время в канал, не блокируясь (как и стандартные таймеры)
+func (w *fakeWaiter) fire(now time.Time) {
+	select {
+	case w.c <- now:
+	default:
+	}
+}
+
+// fakeTimer - Timer фейковых часов
+type fakeTimer struct {
+	*fakeWaiter
+}
+
+func (t fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	return t.fake.remove(t.fakeWaiter)
+}
+
+func (t fakeTimer) Reset(d time.Duration) bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+
+	active := t.fake.remove(t.fakeWaiter)
+	t.fake.schedule(t.fakeWaiter, d)
+	return active
+}
+
+// fakeTicker - Ticker фейковых часов
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t fakeTicker) Stop() {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	t.fake.remove(t.fakeWaiter)
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+
+	t.fake.remove(t.fakeWaiter)
+	t.period = d
+	t.fake.schedule(t.fakeWaiter, d)
+}
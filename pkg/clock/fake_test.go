@@ -0,0 +1,117 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFake_TimerFiresOnAdvance(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(100 * time.Millisecond)
+
+	f.Advance(99 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired too early")
+	default:
+	}
+
+	f.Advance(1 * time.Millisecond)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(epoch.Add(100 * time.Millisecond)) {
+			t.Errorf("timer fired at %v, want %v", at, epoch.Add(100*time.Millisecond))
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+
+	if f.Waiters() != 0 {
+		t.Errorf("expected fired timer to be removed, got %d waiters", f.Waiters())
+	}
+}
+
+func TestFake_TimerStopAndReset(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(time.Second)
+
+	if !timer.Stop() {
+		t.Error("Stop() on active timer should return true")
+	}
+	if timer.Stop() {
+		t.Error("Stop() on stopped timer should return false")
+	}
+
+	f.Advance(2 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	timer.Reset(time.Second)
+	f.Advance(time.Second)
+	select {
+	case <-timer.C():
+	default:
+		t.Fatal("reset timer did not fire")
+	}
+}
+
+func TestFake_Ticker(t *testing.T) {
+	f := NewFake(epoch)
+	ticker := f.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	ticks := 0
+	for i := 0; i < 5; i++ {
+		f.Advance(10 * time.Millisecond)
+		select {
+		case <-ticker.C():
+			ticks++
+		default:
+		}
+	}
+
+	if ticks != 5 {
+		t.Errorf("expected 5 ticks, got %d", ticks)
+	}
+}
+
+func TestFake_AfterAndNow(t *testing.T) {
+	f := NewFake(epoch)
+	ch := f.After(time.Minute)
+
+	f.Advance(time.Hour)
+
+	select {
+	case <-ch:
+	default:
+		t.Fatal("After() channel did not fire")
+	}
+	if !f.Now().Equal(epoch.Add(time.Hour)) {
+		t.Errorf("Now() = %v, want %v", f.Now(), epoch.Add(time.Hour))
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		<-f.After(time.Second)
+	}()
+
+	// Ждем, пока горутина начнет ожидание, и только потом двигаем время
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine was not released by Advance")
+	}
+}
@@ -3,6 +3,8 @@ package limiter
 import (
 	"sync"
 	"time"
+
+	"go-utils/pkg/clock"
 )
 
 // LeakyBucket реализует алгоритм "протекающего ведра" для ограничения скорости
@@ -13,12 +15,20 @@ type LeakyBucket struct {
 	queue    chan struct{} // Ограниченная очередь для хранения запросов
 	mu       sync.Mutex    // Мьютекс для обеспечения потокобезопасности
 	stopCh   chan struct{} // Канал для сигнала остановки
+	clock    clock.Clock   // Источник времени для тикера протекания
 }
 
 // NewLeakyBucket создает новый экземпляр LeakyBucket
 // rate - количество разрешенных запросов в секунду
 // capacity - максимальный размер очереди (емкость ведра)
 func NewLeakyBucket(rate, capacity int64) *LeakyBucket {
+	return NewLeakyBucketWithClock(rate, capacity, clock.New())
+}
+
+// NewLeakyBucketWithClock создает LeakyBucket, который отсчитывает время
+// по переданным часам. В тестах можно передать clock.NewFake и двигать
+// время вручную вместо реального ожидания.
+func NewLeakyBucketWithClock(rate, capacity int64, clk clock.Clock) *LeakyBucket {
 	if rate <= 0 {
 		panic("rate must be greater than 0")
 	}
@@ -31,6 +41,7 @@ func NewLeakyBucket(rate, capacity int64) *LeakyBucket {
 		capacity: capacity,
 		queue:    make(chan struct{}, capacity),
 		stopCh:   make(chan struct{}),
+		clock:    clock.OrDefault(clk),
 	}
 	go lb.leak() // Запускаем горутину для "протекания" ведра
 	return lb
@@ -52,14 +63,14 @@ func (lb *LeakyBucket) Allow() bool {
 // Удаляет запросы из очереди с заданной скоростью
 func (lb *LeakyBucket) leak() {
 	// Создаем тикер с интервалом, соответствующим скорости протекания
-	ticker := time.NewTicker(time.Second / time.Duration(lb.rate))
+	ticker := lb.clock.NewTicker(time.Second / time.Duration(lb.rate))
 	defer ticker.Stop() // Гарантируем остановку тикера при выходе
 
 	for {
 		select {
 		case <-lb.stopCh: // Получен сигнал остановки
 			return // Завершаем работу горутины
-		case <-ticker.C: // Сработал тикер - время "протечь"
+		case <-ticker.C(): // Сработал тикер - время "протечь"
 			select {
 			case <-lb.queue: // Удаляем один запрос из очереди (если есть)
 				// Здесь можно добавить логику обработки запроса
@@ -1,9 +1,12 @@
 package limiter
 
 import (
+	"runtime"
 	"sync"
 	"testing"
 	"time"
+
+	"go-utils/pkg/clock"
 )
 
 func TestNewLeakyBucket(t *testing.T) {
@@ -165,6 +168,64 @@ func TestLeakyBucket_LeakRate(t *testing.T) {
 	}
 }
 
+func TestLeakyBucket_FakeClock(t *testing.T) {
+	rate := int64(5) // 5 запросов в секунду
+	capacity := int64(10)
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	lb := NewLeakyBucketWithClock(rate, capacity, fake)
+	defer lb.Stop()
+
+	// Дожидаемся, пока горутина протекания заведет тикер
+	fake.BlockUntil(1)
+
+	// Заполняем очередь полностью
+	for i := 0; i < int(capacity); i++ {
+		if !lb.Allow() {
+			t.Fatalf("Failed to fill bucket on request %d", i)
+		}
+	}
+
+	// Пока время стоит, ведро не протекает
+	if lb.Allow() {
+		t.Fatal("Allow() should return false when bucket is full and time does not move")
+	}
+
+	// Сдвигаем время на одну секунду по одному тику
+	period := time.Second / time.Duration(rate)
+	for i := 0; i < int(rate); i++ {
+		advanceAndWaitLeak(t, fake, lb, period)
+	}
+
+	// Должно освободиться ровно rate слотов
+	allowedCount := 0
+	for i := 0; i < int(rate)+2; i++ {
+		if lb.Allow() {
+			allowedCount++
+		}
+	}
+
+	if allowedCount != int(rate) {
+		t.Errorf("Expected %d available slots after 1 second, got %d", rate, allowedCount)
+	}
+}
+
+// advanceAndWaitLeak сдвигает фейковое время на один тик и ждет,
+// пока горутина протекания заберет запрос из очереди
+func advanceAndWaitLeak(t *testing.T, fake *clock.Fake, lb *LeakyBucket, period time.Duration) {
+	t.Helper()
+
+	before := len(lb.queue)
+	fake.Advance(period)
+
+	deadline := time.Now().Add(time.Second)
+	for len(lb.queue) >= before {
+		if time.Now().After(deadline) {
+			t.Fatal("bucket did not leak after clock advance")
+		}
+		runtime.Gosched()
+	}
+}
+
 func TestLeakyBucket_ZeroRateEdgeCase(t *testing.T) {
 	defer func() {
 		if r := recover(); r == nil {
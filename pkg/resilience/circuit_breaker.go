@@ -0,0 +1,134 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+// ErrCircuitOpen возвращается, когда предохранитель разомкнут и вызов не выполняется
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// State - состояние предохранителя
+type State int
+
+const (
+	StateClosed   State = iota // Вызовы проходят, ошибки подсчитываются
+	StateOpen                  // Вызовы отклоняются до истечения OpenTimeout
+	StateHalfOpen              // Пропускается пробный вызов для проверки зависимости
+)
+
+// String возвращает название состояния
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerConfig - параметры предохранителя
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Сколько ошибок подряд размыкают предохранитель
+	OpenTimeout      time.Duration // Сколько предохранитель остается разомкнутым
+	Clock            clock.Clock   // Источник времени, по умолчанию реальные часы
+}
+
+// CircuitBreaker - предохранитель, который перестает вызывать зависимость
+// после серии ошибок и через OpenTimeout пропускает один пробный вызов
+type CircuitBreaker struct {
+	mu       sync.Mutex
+	config   CircuitBreakerConfig
+	clock    clock.Clock
+	state    State
+	failures int       // Ошибки подряд в закрытом состоянии
+	openedAt time.Time // Момент размыкания
+	probing  bool      // Выполняется ли пробный вызов в полуоткрытом состоянии
+}
+
+// NewCircuitBreaker создает предохранитель в закрытом состоянии
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	if config.FailureThreshold <= 0 {
+		panic("failure threshold must be greater than 0")
+	}
+
+	return &CircuitBreaker{
+		config: config,
+		clock:  clock.OrDefault(config.Clock),
+	}
+}
+
+// State возвращает текущее состояние предохранителя
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.refresh()
+	return cb.state
+}
+
+// allow решает, можно ли выполнить вызов
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.refresh()
+	switch cb.state {
+	case StateOpen:
+		return ErrCircuitOpen
+	case StateHalfOpen:
+		// В полуоткрытом состоянии пропускаем только один пробный вызов
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+	}
+	return nil
+}
+
+// record учитывает результат вызова
+func (cb *CircuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if err == nil {
+		cb.state = StateClosed
+		cb.failures = 0
+		cb.probing = false
+		return
+	}
+
+	switch cb.state {
+	case StateHalfOpen:
+		// Пробный вызов не удался - снова размыкаемся
+		cb.open()
+	case StateClosed:
+		cb.failures++
+		if cb.failures >= cb.config.FailureThreshold {
+			cb.open()
+		}
+	}
+}
+
+// open размыкает предохранитель. Вызывается под cb.mu.
+func (cb *CircuitBreaker) open() {
+	cb.state = StateOpen
+	cb.openedAt = cb.clock.Now()
+	cb.failures = 0
+	cb.probing = false
+}
+
+// refresh переводит разомкнутый предохранитель в полуоткрытый,
+// если истек OpenTimeout. Вызывается под cb.mu.
+func (cb *CircuitBreaker) refresh() {
+	if cb.state == StateOpen && cb.clock.Now().Sub(cb.openedAt) >= cb.config.OpenTimeout {
+		cb.state = StateHalfOpen
+	}
+}
@@ -0,0 +1,78 @@
+package resilience
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+func TestCircuitBreaker_StateTransitions(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Second,
+		Clock:            fake,
+	})
+	failure := errors.New("failed")
+
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed, got %s", cb.State())
+	}
+
+	// Две ошибки подряд размыкают предохранитель
+	for i := 0; i < 2; i++ {
+		if err := cb.allow(); err != nil {
+			t.Fatalf("unexpected rejection: %v", err)
+		}
+		cb.record(failure)
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open, got %s", cb.State())
+	}
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+
+	// После OpenTimeout пропускается один пробный вызов
+	fake.Advance(time.Second)
+	if cb.State() != StateHalfOpen {
+		t.Fatalf("expected half-open, got %s", cb.State())
+	}
+	if err := cb.allow(); err != nil {
+		t.Fatalf("probe call should be allowed: %v", err)
+	}
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second concurrent probe should be rejected, got %v", err)
+	}
+
+	// Неудачная проба снова размыкает предохранитель
+	cb.record(failure)
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open after failed probe, got %s", cb.State())
+	}
+
+	// Успешная проба замыкает предохранитель
+	fake.Advance(time.Second)
+	if err := cb.allow(); err != nil {
+		t.Fatalf("probe call should be allowed: %v", err)
+	}
+	cb.record(nil)
+	if cb.State() != StateClosed {
+		t.Fatalf("expected closed after successful probe, got %s", cb.State())
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsFailures(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Second})
+	failure := errors.New("failed")
+
+	cb.record(failure)
+	cb.record(nil)
+	cb.record(failure)
+
+	if cb.State() != StateClosed {
+		t.Errorf("non-consecutive failures should not open the circuit, got %s", cb.State())
+	}
+}
@@ -0,0 +1,185 @@
+// Package resilience собирает повторные попытки, таймауты, предохранитель,
+// изоляцию и запасной вариант в одну политику с явным порядком слоев.
+//
+// Слои применяются в порядке объявления: первый объявленный слой - внешний.
+// Например,
+//
+//	policy := resilience.New[string]().
+//	    Fallback(fallback).
+//	    Retry(retryConfig).
+//	    CircuitBreaker(cb).
+//	    Timeout(time.Second).
+//	    Build()
+//
+// выполнит каждую попытку с таймаутом через предохранитель, повторит
+// неудачные попытки, а после исчерпания попыток вызовет fallback.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-utils/pkg/bulkhead"
+	"go-utils/pkg/retry"
+)
+
+// Названия слоев, используемые в RejectedError
+const (
+	LayerRetry          = "retry"
+	LayerTimeout        = "timeout"
+	LayerCircuitBreaker = "circuit_breaker"
+	LayerBulkhead       = "bulkhead"
+	LayerFallback       = "fallback"
+)
+
+// errTimeout - причина отмены контекста слоем Timeout
+var errTimeout = errors.New("policy timeout exceeded")
+
+// RejectedError сообщает, какой слой политики отклонил вызов
+type RejectedError struct {
+	Layer string // Название слоя, отклонившего вызов
+	Err   error  // Причина отклонения
+}
+
+// Error возвращает текст ошибки с названием слоя
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected by %s: %v", e.Layer, e.Err)
+}
+
+// Unwrap возвращает причину отклонения
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// handler - вызов, оборачиваемый слоями политики
+type handler[T any] func(ctx context.Context) (T, error)
+
+// layer - один слой политики
+type layer[T any] struct {
+	name string
+	wrap func(next handler[T]) handler[T]
+}
+
+// Policy - собранная политика, которую можно выполнять многократно и конкурентно
+type Policy[T any] struct {
+	layers []layer[T]
+}
+
+// Execute выполняет fn через все слои политики
+func (p *Policy[T]) Execute(ctx context.Context, fn func(ctx context.Context) (T, error)) (T, error) {
+	h := handler[T](fn)
+	// Оборачиваем с конца, чтобы первый объявленный слой оказался внешним
+	for i := len(p.layers) - 1; i >= 0; i-- {
+		h = p.layers[i].wrap(h)
+	}
+	return h(ctx)
+}
+
+// Layers возвращает названия слоев в порядке применения (от внешнего к внутреннему)
+func (p *Policy[T]) Layers() []string {
+	names := make([]string, len(p.layers))
+	for i, l := range p.layers {
+		names[i] = l.name
+	}
+	return names
+}
+
+// Builder собирает Policy. Каждый вызов добавляет слой внутрь уже объявленных.
+type Builder[T any] struct {
+	layers []layer[T]
+}
+
+// New создает пустой Builder
+func New[T any]() *Builder[T] {
+	return &Builder[T]{}
+}
+
+// Build возвращает политику из объявленных слоев
+func (b *Builder[T]) Build() *Policy[T] {
+	return &Policy[T]{layers: append([]layer[T](nil), b.layers...)}
+}
+
+// Retry добавляет слой повторных попыток с семантикой retry.RetryCtx.
+// Если до дедлайна не успеть сделать следующую попытку, вызов отклоняется.
+func (b *Builder[T]) Retry(config retry.Config) *Builder[T] {
+	return b.add(LayerRetry, func(next handler[T]) handler[T] {
+		return func(ctx context.Context) (T, error) {
+			res, err := retry.RetryCtx(ctx, config, next)
+			if errors.Is(err, retry.ErrInsufficientDeadline) {
+				return res, &RejectedError{Layer: LayerRetry, Err: err}
+			}
+			return res, err
+		}
+	})
+}
+
+// Timeout добавляет слой, ограничивающий время выполнения внутренних слоев
+func (b *Builder[T]) Timeout(d time.Duration) *Builder[T] {
+	return b.add(LayerTimeout, func(next handler[T]) handler[T] {
+		return func(ctx context.Context) (T, error) {
+			ctx, cancel := context.WithTimeoutCause(ctx, d, errTimeout)
+			defer cancel()
+
+			res, err := next(ctx)
+			// Отличаем собственный таймаут слоя от дедлайна внешнего контекста
+			if err != nil && context.Cause(ctx) == errTimeout {
+				return res, &RejectedError{Layer: LayerTimeout, Err: err}
+			}
+			return res, err
+		}
+	})
+}
+
+// CircuitBreaker добавляет слой предохранителя
+func (b *Builder[T]) CircuitBreaker(cb *CircuitBreaker) *Builder[T] {
+	return b.add(LayerCircuitBreaker, func(next handler[T]) handler[T] {
+		return func(ctx context.Context) (T, error) {
+			if err := cb.allow(); err != nil {
+				var zero T
+				return zero, &RejectedError{Layer: LayerCircuitBreaker, Err: err}
+			}
+
+			res, err := next(ctx)
+			cb.record(err)
+			return res, err
+		}
+	})
+}
+
+// Bulkhead добавляет слой изоляции. Если переборка отклонила вызов
+// (нет слотов и места в очереди или истек таймаут очереди), возвращается
+// RejectedError с причиной из пакета bulkhead.
+func (b *Builder[T]) Bulkhead(bh *bulkhead.Bulkhead) *Builder[T] {
+	return b.add(LayerBulkhead, func(next handler[T]) handler[T] {
+		return func(ctx context.Context) (T, error) {
+			res, err := bulkhead.Do(ctx, bh, next)
+
+			var rejected *bulkhead.RejectedError
+			if errors.As(err, &rejected) {
+				return res, &RejectedError{Layer: LayerBulkhead, Err: err}
+			}
+			return res, err
+		}
+	})
+}
+
+// Fallback добавляет слой, который при ошибке внутренних слоев вызывает fn.
+// fn получает исходную ошибку и может вернуть запасной результат.
+func (b *Builder[T]) Fallback(fn func(ctx context.Context, err error) (T, error)) *Builder[T] {
+	return b.add(LayerFallback, func(next handler[T]) handler[T] {
+		return func(ctx context.Context) (T, error) {
+			res, err := next(ctx)
+			if err == nil {
+				return res, nil
+			}
+			return fn(ctx, err)
+		}
+	})
+}
+
+func (b *Builder[T]) add(name string, wrap func(next handler[T]) handler[T]) *Builder[T] {
+	b.layers = append(b.layers, layer[T]{name: name, wrap: wrap})
+	return b
+}
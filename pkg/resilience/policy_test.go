@@ -0,0 +1,198 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go-utils/pkg/bulkhead"
+	"go-utils/pkg/retry"
+)
+
+var testRetryConfig = retry.Config{
+	MaxAttempts:  3,
+	InitialDelay: time.Millisecond,
+	MaxDelay:     5 * time.Millisecond,
+}
+
+func TestPolicy_LayersOrder(t *testing.T) {
+	var trace []string
+	record := func(name string) func(ctx context.Context, err error) (string, error) {
+		return func(ctx context.Context, err error) (string, error) {
+			trace = append(trace, name)
+			return "", err
+		}
+	}
+
+	policy := New[string]().
+		Fallback(record("outer")).
+		Fallback(record("inner")).
+		Build()
+
+	policy.Execute(context.Background(), func(ctx context.Context) (string, error) {
+		return "", errors.New("failed")
+	})
+
+	// Внутренний слой получает ошибку первым
+	if len(trace) != 2 || trace[0] != "inner" || trace[1] != "outer" {
+		t.Errorf("unexpected layer order: %v", trace)
+	}
+	if got := policy.Layers(); len(got) != 2 || got[0] != LayerFallback {
+		t.Errorf("unexpected layers: %v", got)
+	}
+}
+
+func TestPolicy_RetryThenFallback(t *testing.T) {
+	calls := 0
+	policy := New[string]().
+		Fallback(func(ctx context.Context, err error) (string, error) {
+			return "fallback", nil
+		}).
+		Retry(testRetryConfig).
+		Build()
+
+	result, err := policy.Execute(context.Background(), func(ctx context.Context) (string, error) {
+		calls++
+		return "", errors.New("failed")
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "fallback" {
+		t.Errorf("expected fallback result, got %q", result)
+	}
+	if calls != testRetryConfig.MaxAttempts {
+		t.Errorf("expected %d calls, got %d", testRetryConfig.MaxAttempts, calls)
+	}
+}
+
+func TestPolicy_TimeoutRejects(t *testing.T) {
+	policy := New[string]().
+		Timeout(10 * time.Millisecond).
+		Build()
+
+	_, err := policy.Execute(context.Background(), func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("expected RejectedError, got %v", err)
+	}
+	if rejected.Layer != LayerTimeout {
+		t.Errorf("expected rejection by %s, got %s", LayerTimeout, rejected.Layer)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestPolicy_TimeoutRetriedPerAttempt(t *testing.T) {
+	calls := 0
+	policy := New[string]().
+		Retry(testRetryConfig).
+		Timeout(10 * time.Millisecond).
+		Build()
+
+	result, err := policy.Execute(context.Background(), func(ctx context.Context) (string, error) {
+		calls++
+		if calls == 1 {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return "success", nil
+	})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "success" || calls != 2 {
+		t.Errorf("expected success on 2nd attempt, got %q after %d calls", result, calls)
+	}
+}
+
+func TestPolicy_CircuitBreakerRejects(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+	policy := New[string]().
+		CircuitBreaker(cb).
+		Build()
+
+	failing := func(ctx context.Context) (string, error) {
+		return "", errors.New("failed")
+	}
+	policy.Execute(context.Background(), failing)
+	policy.Execute(context.Background(), failing)
+
+	called := false
+	_, err := policy.Execute(context.Background(), func(ctx context.Context) (string, error) {
+		called = true
+		return "ok", nil
+	})
+
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || rejected.Layer != LayerCircuitBreaker {
+		t.Fatalf("expected rejection by circuit breaker, got %v", err)
+	}
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+	if called {
+		t.Error("function should not be called when circuit is open")
+	}
+}
+
+func TestPolicy_BulkheadRejects(t *testing.T) {
+	policy := New[string]().
+		Bulkhead(bulkhead.New(bulkhead.Config{MaxConcurrent: 1})).
+		Build()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		policy.Execute(context.Background(), func(ctx context.Context) (string, error) {
+			close(started)
+			<-release
+			return "ok", nil
+		})
+	}()
+	<-started
+
+	_, err := policy.Execute(context.Background(), func(ctx context.Context) (string, error) {
+		return "ok", nil
+	})
+	close(release)
+	wg.Wait()
+
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || rejected.Layer != LayerBulkhead {
+		t.Fatalf("expected rejection by bulkhead, got %v", err)
+	}
+	if !errors.Is(err, bulkhead.ErrFull) {
+		t.Errorf("expected bulkhead.ErrFull, got %v", err)
+	}
+}
+
+func TestPolicy_RetryRejectsOnShortDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	policy := New[string]().
+		Retry(retry.Config{MaxAttempts: 3, InitialDelay: time.Second, MaxDelay: time.Second}).
+		Build()
+
+	_, err := policy.Execute(ctx, func(ctx context.Context) (string, error) {
+		return "", errors.New("failed")
+	})
+
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) || rejected.Layer != LayerRetry {
+		t.Fatalf("expected rejection by retry, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+// AdaptiveConfig - параметры адаптивного ограничителя скорости
+type AdaptiveConfig struct {
+	InitialRate float64          // Начальная скорость отправки (попыток в секунду)
+	MinRate     float64          // Нижняя граница скорости
+	MaxRate     float64          // Верхняя граница скорости
+	Increase    float64          // Аддитивный прирост скорости после успешной попытки
+	Decrease    float64          // Множитель скорости при троттлинге (от 0 до 1)
+	Burst       float64          // Сколько попыток можно отправить разом, по умолчанию 1
+	IsThrottle  func(error) bool // Классификатор троттлинга, по умолчанию IsThrottleError
+	Clock       clock.Clock      // Источник времени, по умолчанию реальные часы
+}
+
+// Adaptive - клиентский ограничитель скорости по схеме AIMD
+// (additive increase, multiplicative decrease), как в adaptive retry mode AWS SDK.
+// При троттлинге скорость умножается на Decrease, при успехе растет на Increase.
+//
+// Один Adaptive можно разделять между несколькими вызовами Retry
+// (через Config.Adaptive), тогда их суммарная нагрузка подстраивается под
+// то, что выдерживает сервер. Безопасен для конкурентного использования.
+type Adaptive struct {
+	mu     sync.Mutex
+	config AdaptiveConfig
+	clock  clock.Clock
+	rate   float64   // Текущая скорость отправки
+	tokens float64   // Накопленные токены
+	last   time.Time // Момент последнего пополнения токенов
+}
+
+// NewAdaptive создает адаптивный ограничитель скорости
+func NewAdaptive(config AdaptiveConfig) *Adaptive {
+	if config.MinRate <= 0 {
+		panic("min rate must be greater than 0")
+	}
+	if config.MaxRate < config.MinRate {
+		panic("max rate must not be less than min rate")
+	}
+	if config.Decrease <= 0 || config.Decrease >= 1 {
+		panic("decrease must be between 0 and 1")
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.IsThrottle == nil {
+		config.IsThrottle = IsThrottleError
+	}
+
+	clk := clock.OrDefault(config.Clock)
+	return &Adaptive{
+		config: config,
+		clock:  clk,
+		rate:   min(max(config.InitialRate, config.MinRate), config.MaxRate),
+		tokens: config.Burst,
+		last:   clk.Now(),
+	}
+}
+
+// Rate возвращает текущую скорость отправки (попыток в секунду)
+func (a *Adaptive) Rate() float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.rate
+}
+
+// Wait ждет, пока текущая скорость позволит отправить следующую попытку
+func (a *Adaptive) Wait(ctx context.Context) error {
+	for {
+		wait := a.reserve()
+		if wait == 0 {
+			return nil
+		}
+
+		timer := a.clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+			// Токен мог достаться другому вызову - проверяем снова
+		}
+	}
+}
+
+// Feedback учитывает результат попытки и корректирует скорость
+func (a *Adaptive) Feedback(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case err == nil:
+		a.rate = min(a.rate+a.config.Increase, a.config.MaxRate)
+	case a.config.IsThrottle(err):
+		a.rate = max(a.rate*a.config.Decrease, a.config.MinRate)
+	}
+	// Прочие ошибки не говорят о перегрузке сервера и скорость не меняют
+}
+
+// reserve забирает токен, если он есть, иначе возвращает время до появления токена
+func (a *Adaptive) reserve() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.clock.Now()
+	a.tokens = min(a.tokens+now.Sub(a.last).Seconds()*a.rate, a.config.Burst)
+	a.last = now
+
+	if a.tokens >= 1 {
+		a.tokens--
+		return 0
+	}
+
+	wait := time.Duration((1 - a.tokens) / a.rate * float64(time.Second))
+	return max(wait, time.Nanosecond)
+}
+
+// throttler реализуется ошибками, которые сами сообщают о троттлинге
+type throttler interface {
+	Throttled() bool
+}
+
+// IsThrottleError сообщает, говорит ли ошибка о том, что сервер перегружен:
+//   - ошибка несет задержку Retry-After (RetryAfterer)
+//   - ошибка реализует метод Throttled() bool и возвращает true
+//   - это ответ Transport с кодом 429 или 503
+func IsThrottleError(err error) bool {
+	var t throttler
+	if errors.As(err, &t) {
+		return t.Throttled()
+	}
+
+	var ra RetryAfterer
+	if errors.As(err, &ra) {
+		return true
+	}
+
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code == http.StatusServiceUnavailable
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+func newTestAdaptive(fake *clock.Fake) *Adaptive {
+	return NewAdaptive(AdaptiveConfig{
+		InitialRate: 10,
+		MinRate:     1,
+		MaxRate:     20,
+		Increase:    1,
+		Decrease:    0.5,
+		Clock:       fake,
+	})
+}
+
+func TestAdaptive_AIMD(t *testing.T) {
+	a := newTestAdaptive(clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	throttled := &RetryAfterError{Err: errors.New("slow down"), Delay: time.Second}
+
+	a.Feedback(nil)
+	if a.Rate() != 11 {
+		t.Errorf("expected additive increase to 11, got %v", a.Rate())
+	}
+
+	a.Feedback(throttled)
+	if a.Rate() != 5.5 {
+		t.Errorf("expected multiplicative decrease to 5.5, got %v", a.Rate())
+	}
+
+	// Ошибки, не связанные с троттлингом, скорость не меняют
+	a.Feedback(errors.New("bad request"))
+	if a.Rate() != 5.5 {
+		t.Errorf("expected rate to stay 5.5, got %v", a.Rate())
+	}
+
+	// Скорость не опускается ниже MinRate и не поднимается выше MaxRate
+	for i := 0; i < 10; i++ {
+		a.Feedback(throttled)
+	}
+	if a.Rate() != 1 {
+		t.Errorf("expected rate to be clamped to MinRate, got %v", a.Rate())
+	}
+	for i := 0; i < 100; i++ {
+		a.Feedback(nil)
+	}
+	if a.Rate() != 20 {
+		t.Errorf("expected rate to be clamped to MaxRate, got %v", a.Rate())
+	}
+}
+
+func TestAdaptive_WaitFollowsRate(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	a := newTestAdaptive(fake)
+
+	// Первый токен доступен сразу
+	if err := a.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Следующий - через 1/rate секунды
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Wait(context.Background())
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(99 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("Wait returned before the next token was available")
+	default:
+	}
+
+	fake.Advance(time.Millisecond)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after the token became available")
+	}
+}
+
+func TestAdaptive_WaitRespectsContext(t *testing.T) {
+	a := newTestAdaptive(clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	a.Wait(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := a.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRetry_SharedAdaptiveSlowsDownOnThrottling(t *testing.T) {
+	a := NewAdaptive(AdaptiveConfig{
+		InitialRate: 1000,
+		MinRate:     1,
+		MaxRate:     1000,
+		Increase:    1,
+		Decrease:    0.5,
+		Burst:       10,
+	})
+	config := Config{
+		MaxAttempts:  3,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Adaptive:     a,
+	}
+
+	called := 0
+	Retry(context.Background(), config, func() (string, error) {
+		called++
+		return "", &statusError{code: 429}
+	})
+
+	if called != 3 {
+		t.Errorf("expected 3 calls, got %d", called)
+	}
+	if a.Rate() != 125 {
+		t.Errorf("expected rate to be halved on each throttled attempt (125), got %v", a.Rate())
+	}
+}
+
+func TestIsThrottleError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Retry-After", &RetryAfterError{Err: errors.New("x"), Delay: time.Second}, true},
+		{"429", &statusError{code: 429}, true},
+		{"503", &statusError{code: 503}, true},
+		{"502", &statusError{code: 502}, false},
+		{"Plain error", errors.New("x"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsThrottleError(tt.err); got != tt.want {
+				t.Errorf("IsThrottleError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,18 @@ package retry
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math/rand/v2"
 	"time"
+
+	"go-utils/pkg/clock"
 )
 
+// ErrInsufficientDeadline возвращается, когда до дедлайна контекста не успеть
+// выполнить задержку и еще одну попытку
+var ErrInsufficientDeadline = errors.New("retry: not enough time left before deadline")
+
 // Retry выполняет операцию с повторными попытками согласно конфигурации.
 // Параметры:
 //   - ctx: контекст для контроля выполнения и отмены
@@ -16,8 +24,22 @@ import (
 //   - результат успешного выполнения операции
 //   - ошибку (последнюю ошибку операции или ошибку контекста)
 func Retry[T any](ctx context.Context, config Config, operation func() (T, error)) (T, error) {
+	return RetryCtx(ctx, config, func(context.Context) (T, error) {
+		return operation()
+	})
+}
+
+// RetryCtx работает как Retry, но передает контекст в каждую попытку.
+// Если в конфигурации задан AttemptTimeout, каждая попытка получает
+// собственный контекст с этим таймаутом.
+//
+// Если у ctx есть дедлайн и оставшегося времени не хватает на очередную
+// задержку и попытку, RetryCtx не ждет впустую, а сразу возвращает
+// ErrInsufficientDeadline вместе с последней ошибкой операции.
+func RetryCtx[T any](ctx context.Context, config Config, operation func(ctx context.Context) (T, error)) (T, error) {
 	var result T
 	var err error
+	clk := clock.OrDefault(config.Clock)
 	currentDelay := config.InitialDelay // Текущая задержка между попытками
 
 	// Основной цикл попыток выполнения
@@ -27,15 +49,25 @@ func Retry[T any](ctx context.Context, config Config, operation func() (T, error
 			return result, ctx.Err()
 		}
 
+		// Ждем разрешения адаптивного ограничителя скорости
+		if config.Adaptive != nil {
+			if waitErr := config.Adaptive.Wait(ctx); waitErr != nil {
+				return result, waitErr
+			}
+		}
+
 		// Выполняем операцию
-		result, err = operation()
+		result, err = runAttempt(ctx, config.AttemptTimeout, operation)
+		if config.Adaptive != nil {
+			config.Adaptive.Feedback(err)
+		}
 		if err == nil {
 			// Успешное выполнение - возвращаем результат
 			return result, nil
 		}
 
-		// Если это была последняя попытка - возвращаем ошибку
-		if attempt == config.MaxAttempts {
+		// Если это была последняя попытка или ошибку нельзя повторять - возвращаем ее
+		if attempt == config.MaxAttempts || (config.RetryIf != nil && !config.RetryIf(err)) {
 			return result, err
 		}
 
@@ -47,11 +79,27 @@ func Retry[T any](ctx context.Context, config Config, operation func() (T, error
 			currentDelay = config.MaxDelay
 		}
 
+		// Если сервер явно указал, сколько ждать, используем его задержку,
+		// но не больше MaxDelay
+		wait := currentDelay
+		if delay, ok := retryAfterDelay(err); ok {
+			wait = min(delay, config.MaxDelay)
+		}
+
+		// Проверяем, что до дедлайна успеем подождать и сделать еще одну попытку.
+		// Дедлайн контекста всегда задан по реальному времени, поэтому
+		// сравнивается с ним, а не с config.Clock
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait+config.AttemptTimeout {
+			return result, fmt.Errorf("%w: %w", ErrInsufficientDeadline, err)
+		}
+
 		// Ожидаем перед следующей попыткой с возможностью прерывания
+		timer := clk.NewTimer(wait)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			return result, ctx.Err()
-		case <-time.After(currentDelay):
+		case <-timer.C():
 			// Удваиваем задержку для следующей попытки (экспоненциальный рост)
 			currentDelay *= 2
 		}
@@ -59,3 +107,15 @@ func Retry[T any](ctx context.Context, config Config, operation func() (T, error
 
 	return result, err
 }
+
+// runAttempt выполняет одну попытку, ограничивая ее по времени, если задан таймаут
+func runAttempt[T any](ctx context.Context, timeout time.Duration, operation func(ctx context.Context) (T, error)) (T, error) {
+	if timeout <= 0 {
+		return operation(ctx)
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return operation(attemptCtx)
+}
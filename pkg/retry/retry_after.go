@@ -0,0 +1,102 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryAfterer реализуется ошибками, которые несут рекомендованную сервером
+// задержку перед следующей попыткой (например, из заголовка Retry-After)
+type RetryAfterer interface {
+	RetryAfter() time.Duration
+}
+
+// RetryAfterError оборачивает ошибку операции и хранит задержку,
+// которую запросил сервер
+type RetryAfterError struct {
+	Err   error         // Исходная ошибка
+	Delay time.Duration // Рекомендованная задержка перед повтором
+}
+
+// Error возвращает текст ошибки вместе с рекомендованной задержкой
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %s)", e.Err, e.Delay)
+}
+
+// Unwrap возвращает исходную ошибку для errors.Is/errors.As
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter возвращает рекомендованную задержку
+func (e *RetryAfterError) RetryAfter() time.Duration {
+	return e.Delay
+}
+
+// RetryAfterFromHeader разбирает значение HTTP-заголовка Retry-After и
+// оборачивает err в RetryAfterError с полученной задержкой.
+// Если заголовок пустой или некорректный, err возвращается без изменений.
+func RetryAfterFromHeader(err error, header string) error {
+	if err == nil {
+		return nil
+	}
+
+	delay, ok := ParseRetryAfter(header, time.Now())
+	if !ok {
+		return err
+	}
+
+	return &RetryAfterError{Err: err, Delay: delay}
+}
+
+// ParseRetryAfter разбирает значение заголовка Retry-After.
+// Поддерживаются оба формата из RFC 9110:
+//   - количество секунд ("120")
+//   - HTTP-дата ("Wed, 21 Oct 2015 07:28:00 GMT"), задержка считается от now
+//
+// Дата в прошлом дает нулевую задержку. Второе значение равно false,
+// если заголовок не удалось разобрать.
+func ParseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+
+	// Формат delay-seconds
+	if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	// Формат HTTP-date
+	at, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+
+	delay := at.Sub(now)
+	if delay < 0 {
+		delay = 0
+	}
+	return delay, true
+}
+
+// retryAfterDelay ищет в цепочке ошибок рекомендованную сервером задержку
+func retryAfterDelay(err error) (time.Duration, bool) {
+	var ra RetryAfterer
+	if !errors.As(err, &ra) {
+		return 0, false
+	}
+
+	delay := ra.RetryAfter()
+	if delay < 0 {
+		return 0, false
+	}
+	return delay, true
+}
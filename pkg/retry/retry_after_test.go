@@ -0,0 +1,120 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, time.October, 21, 7, 28, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		header    string
+		wantDelay time.Duration
+		wantOK    bool
+	}{
+		{"Seconds", "120", 120 * time.Second, true},
+		{"Zero seconds", "0", 0, true},
+		{"Seconds with spaces", " 5 ", 5 * time.Second, true},
+		{"HTTP date in future", "Wed, 21 Oct 2015 07:28:30 GMT", 30 * time.Second, true},
+		{"HTTP date in past", "Wed, 21 Oct 2015 07:27:00 GMT", 0, true},
+		{"Empty", "", 0, false},
+		{"Negative seconds", "-1", 0, false},
+		{"Garbage", "soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, ok := ParseRetryAfter(tt.header, now)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseRetryAfter(%q) ok = %v, want %v", tt.header, ok, tt.wantOK)
+			}
+			if delay != tt.wantDelay {
+				t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.header, delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestRetryAfterFromHeader(t *testing.T) {
+	baseErr := errors.New("too many requests")
+
+	err := RetryAfterFromHeader(baseErr, "3")
+
+	var ra RetryAfterer
+	if !errors.As(err, &ra) {
+		t.Fatalf("expected error to implement RetryAfterer, got %T", err)
+	}
+	if ra.RetryAfter() != 3*time.Second {
+		t.Errorf("expected 3s delay, got %v", ra.RetryAfter())
+	}
+	if !errors.Is(err, baseErr) {
+		t.Errorf("expected wrapped error to match base error")
+	}
+
+	// Некорректный заголовок не меняет ошибку
+	if got := RetryAfterFromHeader(baseErr, "later"); got != baseErr {
+		t.Errorf("expected original error, got %v", got)
+	}
+}
+
+func TestRetry_HonorsRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	config := Config{
+		MaxAttempts:  2,
+		InitialDelay: 1 * time.Second,
+		MaxDelay:     2 * time.Second,
+	}
+
+	called := 0
+	op := func() (string, error) {
+		called++
+		if called == 1 {
+			return "", &RetryAfterError{Err: errors.New("throttled"), Delay: 10 * time.Millisecond}
+		}
+		return "success", nil
+	}
+
+	start := time.Now()
+	result, err := Retry(ctx, config, op)
+	elapsed := time.Since(start)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "success" {
+		t.Errorf("expected 'success', got %v", result)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("server delay was ignored, elapsed %v", elapsed)
+	}
+}
+
+func TestRetry_RetryAfterCappedByMaxDelay(t *testing.T) {
+	ctx := context.Background()
+	config := Config{
+		MaxAttempts:  2,
+		InitialDelay: 10 * time.Millisecond,
+		MaxDelay:     50 * time.Millisecond,
+	}
+
+	called := 0
+	op := func() (string, error) {
+		called++
+		return "", &RetryAfterError{Err: errors.New("throttled"), Delay: 10 * time.Second}
+	}
+
+	start := time.Now()
+	Retry(ctx, config, op)
+	elapsed := time.Since(start)
+
+	if called != 2 {
+		t.Errorf("expected 2 calls, got %d", called)
+	}
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("server delay was not capped by MaxDelay, elapsed %v", elapsed)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"testing"
 	"time"
+
+	"go-utils/pkg/clock"
 )
 
 func TestRetry_SuccessOnFirstAttempt(t *testing.T) {
@@ -122,62 +124,255 @@ func TestRetry_ContextCancellation(t *testing.T) {
 }
 
 func TestRetry_DelayWithJitter(t *testing.T) {
-	ctx := context.Background()
 	config := Config{
 		MaxAttempts:  3,
 		InitialDelay: 100 * time.Millisecond,
 		MaxDelay:     1 * time.Second,
 	}
 
-	called := 0
 	op := func() (string, error) {
-		called++
 		return "", errors.New("operation failed")
 	}
 
-	start := time.Now()
-	Retry(ctx, config, op)
-	elapsed := time.Since(start)
+	delays := retryDelays(t, config, op)
 
-	// Проверяем, что общее время выполнения соответствует ожидаемым задержкам
-	// с учетом джиттера (100ms + ~200ms + ~400ms = ~700ms)
-	minExpected := 600 * time.Millisecond
-	maxExpected := 800 * time.Millisecond
-	if elapsed < minExpected || elapsed > maxExpected {
-		t.Errorf("elapsed time %v outside expected range (%v-%v)", elapsed, minExpected, maxExpected)
+	// Первая задержка: 100ms + джиттер (0-100ms)
+	// Вторая задержка: удвоенная первая (200-400ms) + джиттер того же размера
+	if len(delays) != 2 {
+		t.Fatalf("expected 2 delays, got %d", len(delays))
+	}
+	if delays[0] < 100*time.Millisecond || delays[0] > 200*time.Millisecond {
+		t.Errorf("first delay %v outside expected range (100ms-200ms)", delays[0])
+	}
+	if delays[1] < 2*delays[0]-2*time.Millisecond || delays[1] > 4*delays[0] {
+		t.Errorf("second delay %v outside expected range (%v-%v)", delays[1], 2*delays[0], 4*delays[0])
 	}
 }
 
 func TestRetry_MaxDelayRespected(t *testing.T) {
-	ctx := context.Background()
 	config := Config{
 		MaxAttempts:  4,
 		InitialDelay: 500 * time.Millisecond,
 		MaxDelay:     1 * time.Second,
 	}
 
-	called := 0
 	op := func() (string, error) {
-		called++
 		return "", errors.New("operation failed")
 	}
 
-	start := time.Now()
-	Retry(ctx, config, op)
-	elapsed := time.Since(start)
+	delays := retryDelays(t, config, op)
 
 	// Ожидаемые задержки:
 	// 1 попытка: 500ms (initial) + jitter (~0-500ms)
 	// 2 попытка: min(1s + jitter, 1s)
 	// 3 попытка: min(1s + jitter, 1s)
-	// Общее время: ~500ms-1s + ~1s + ~1s = ~2.5s-3s
+	if len(delays) != 3 {
+		t.Fatalf("expected 3 delays, got %d", len(delays))
+	}
+	if delays[0] < 500*time.Millisecond || delays[0] > config.MaxDelay {
+		t.Errorf("first delay %v outside expected range (500ms-1s)", delays[0])
+	}
+	for i, d := range delays[1:] {
+		if d != config.MaxDelay {
+			t.Errorf("delay %d is %v, expected it to be capped at %v", i+2, d, config.MaxDelay)
+		}
+	}
+}
+
+// retryDelays запускает Retry на фейковых часах и измеряет каждую задержку
+// между попытками, продвигая время по миллисекунде
+func retryDelays(t *testing.T, config Config, op func() (string, error)) []time.Duration {
+	t.Helper()
+
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	config.Clock = fake
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Retry(context.Background(), config, op)
+	}()
+
+	var delays []time.Duration
+	for i := 1; i < config.MaxAttempts; i++ {
+		// Ждем, пока Retry заснет перед следующей попыткой
+		fake.BlockUntil(1)
+		start := fake.Now()
+		for fake.Waiters() > 0 {
+			fake.Advance(time.Millisecond)
+		}
+		delays = append(delays, fake.Now().Sub(start))
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Retry did not finish")
+	}
+	return delays
+}
+
+func TestRetryCtx_PassesContext(t *testing.T) {
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	config := Config{
+		MaxAttempts:  1,
+		InitialDelay: 10 * time.Millisecond,
+		MaxDelay:     100 * time.Millisecond,
+	}
+
+	result, err := RetryCtx(ctx, config, func(ctx context.Context) (string, error) {
+		v, _ := ctx.Value(ctxKey{}).(string)
+		return v, nil
+	})
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if result != "value" {
+		t.Errorf("expected context value to be passed, got %q", result)
+	}
+}
+
+func TestRetryCtx_AttemptTimeout(t *testing.T) {
+	ctx := context.Background()
+	config := Config{
+		MaxAttempts:    3,
+		InitialDelay:   10 * time.Millisecond,
+		MaxDelay:       10 * time.Millisecond,
+		AttemptTimeout: 20 * time.Millisecond,
+	}
+
+	called := 0
+	op := func(ctx context.Context) (string, error) {
+		called++
+		if called < 3 {
+			// Зависшая попытка прерывается собственным таймаутом
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return "success", nil
+	}
+
+	result, err := RetryCtx(ctx, config, op)
+
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if result != "success" {
+		t.Errorf("expected 'success', got %v", result)
+	}
+	if called != 3 {
+		t.Errorf("expected 3 calls, got %d", called)
+	}
+}
+
+func TestRetryCtx_InsufficientDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	config := Config{
+		MaxAttempts:  3,
+		InitialDelay: 1 * time.Second,
+		MaxDelay:     1 * time.Second,
+	}
+
+	expectedErr := errors.New("operation failed")
+	called := 0
+	op := func(ctx context.Context) (string, error) {
+		called++
+		return "", expectedErr
+	}
+
+	start := time.Now()
+	_, err := RetryCtx(ctx, config, op)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, ErrInsufficientDeadline) {
+		t.Errorf("expected ErrInsufficientDeadline, got %v", err)
+	}
+	if !errors.Is(err, expectedErr) {
+		t.Errorf("expected last operation error to be wrapped, got %v", err)
+	}
+	if called != 1 {
+		t.Errorf("expected 1 call, got %d", called)
+	}
+	if elapsed > 50*time.Millisecond {
+		t.Errorf("retry should return before the deadline, took %v", elapsed)
+	}
+}
 
-	minExpected := 2 * time.Second
-	maxExpected := 3 * time.Second
-	if elapsed < minExpected {
-		t.Errorf("elapsed time %v is less than minimum expected %v", elapsed, minExpected)
+func TestRetry_RetryIfStopsOnPermanentError(t *testing.T) {
+	ctx := context.Background()
+	permanentErr := errors.New("permanent error")
+	config := Config{
+		MaxAttempts:  5,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		RetryIf: func(err error) bool {
+			return !errors.Is(err, permanentErr)
+		},
+	}
+
+	called := 0
+	op := func() (string, error) {
+		called++
+		if called < 2 {
+			return "", errors.New("temporary error")
+		}
+		return "", permanentErr
 	}
-	if elapsed > maxExpected {
-		t.Errorf("elapsed time %v is greater than maximum expected %v", elapsed, maxExpected)
+
+	_, err := Retry(ctx, config, op)
+
+	if !errors.Is(err, permanentErr) {
+		t.Errorf("expected %v, got %v", permanentErr, err)
+	}
+	if called != 2 {
+		t.Errorf("expected 2 calls, got %d", called)
+	}
+}
+
+func TestRetryCtx_DeadlineUsesRealTime(t *testing.T) {
+	// Фейковые часы далеко в будущем не должны влиять на проверку дедлайна
+	fake := clock.NewFake(time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	config := Config{
+		MaxAttempts:  2,
+		InitialDelay: time.Millisecond,
+		MaxDelay:     time.Millisecond,
+		Clock:        fake,
+	}
+
+	done := make(chan error, 1)
+	called := 0
+	go func() {
+		_, err := RetryCtx(ctx, config, func(ctx context.Context) (string, error) {
+			called++
+			if called == 1 {
+				return "", errors.New("temporary")
+			}
+			return "ok", nil
+		})
+		done <- err
+	}()
+
+	// Продвигаем фейковые часы, пока RetryCtx ждет перед повтором
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			return
+		case <-timeout:
+			t.Fatal("RetryCtx did not finish")
+		default:
+			if fake.Waiters() > 0 {
+				fake.Advance(time.Millisecond)
+			}
+			time.Sleep(time.Millisecond)
+		}
 	}
 }
@@ -1,9 +1,24 @@
 package retry
 
-import "time"
+import (
+	"time"
+
+	"go-utils/pkg/clock"
+)
 
 type Config struct {
 	MaxAttempts  int
 	InitialDelay time.Duration
 	MaxDelay     time.Duration
+	// AttemptTimeout ограничивает длительность одной попытки в RetryCtx.
+	// Нулевое значение - без отдельного таймаута.
+	AttemptTimeout time.Duration
+	// Clock - источник времени для задержек. Если nil, используются реальные часы.
+	Clock clock.Clock
+	// Adaptive - общий адаптивный ограничитель скорости. Если задан, каждая
+	// попытка ждет разрешения от него, а ее результат корректирует скорость.
+	Adaptive *Adaptive
+	// RetryIf решает, стоит ли повторять операцию после ошибки.
+	// Если nil, повторяются любые ошибки.
+	RetryIf func(err error) bool
 }
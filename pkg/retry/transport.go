@@ -0,0 +1,179 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+// DefaultRetryStatuses - коды ответов, при которых Transport по умолчанию повторяет запрос
+var DefaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// Transport - http.RoundTripper, повторяющий запросы через Retry.
+// Повторы выполняются при ошибках соединения и при ответах с кодами из
+// RetryStatuses. Задержка из заголовка Retry-After учитывается так же,
+// как в Retry. Тела отброшенных ответов вычитываются и закрываются.
+//
+// По умолчанию повторяются только идемпотентные запросы (GET, HEAD, OPTIONS,
+// TRACE, PUT, DELETE) и запросы с заголовком Idempotency-Key. Запросы с телом
+// повторяются, только если у них задан GetBody.
+type Transport struct {
+	Base               http.RoundTripper // Нижележащий транспорт, по умолчанию http.DefaultTransport
+	Config             Config            // Параметры повторных попыток, MaxAttempts < 1 означает одну попытку
+	RetryStatuses      []int             // Коды ответов для повтора, по умолчанию DefaultRetryStatuses
+	RetryNonIdempotent bool              // Повторять ли неидемпотентные запросы (POST, PATCH)
+}
+
+// NewTransport создает Transport поверх base с конфигурацией повторов config
+func NewTransport(base http.RoundTripper, config Config) *Transport {
+	return &Transport{
+		Base:   base,
+		Config: config,
+	}
+}
+
+// statusError - ответ с кодом, при котором нужно повторить запрос
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("retryable response status: %d %s", e.code, http.StatusText(e.code))
+}
+
+// RoundTrip выполняет запрос с повторными попытками.
+// Если все попытки закончились ответом с кодом для повтора, возвращается
+// последний такой ответ без ошибки - как это сделал бы обычный транспорт.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base()
+	if !t.canRetry(req) {
+		return base.RoundTrip(req)
+	}
+
+	config := t.Config
+	// Таймаут попытки отменил бы контекст до того, как вызывающий прочитает
+	// тело ответа, поэтому время запроса ограничивается только контекстом req
+	config.AttemptTimeout = 0
+	// Без попыток Retry вернул бы (nil, nil), что нарушает контракт RoundTripper
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+
+	var last *http.Response // Последний ответ с кодом для повтора
+	attempt := 0
+
+	resp, err := Retry(req.Context(), config, func() (*http.Response, error) {
+		attempt++
+
+		// Предыдущий ответ больше не нужен - освобождаем соединение
+		if last != nil {
+			discard(last)
+			last = nil
+		}
+
+		r, err := prepareAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(t.retryStatuses(), resp.StatusCode) {
+			return resp, nil
+		}
+
+		last = resp
+		return nil, RetryAfterFromHeader(&statusError{code: resp.StatusCode}, resp.Header.Get("Retry-After"))
+	})
+	if err == nil {
+		return resp, nil
+	}
+
+	// Попытки исчерпаны на ответе с кодом для повтора - отдаем этот ответ
+	var se *statusError
+	if last != nil && errors.As(err, &se) {
+		return last, nil
+	}
+
+	if last != nil {
+		discard(last)
+	}
+	return nil, err
+}
+
+// canRetry проверяет, можно ли безопасно повторить запрос
+func (t *Transport) canRetry(req *http.Request) bool {
+	// Тело без GetBody нельзя отправить повторно
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	return t.RetryNonIdempotent || isIdempotent(req)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+	return t.Base
+}
+
+func (t *Transport) retryStatuses() []int {
+	if t.RetryStatuses == nil {
+		return DefaultRetryStatuses
+	}
+	return t.RetryStatuses
+}
+
+// prepareAttempt возвращает запрос для очередной попытки.
+// Первая попытка использует исходный запрос, следующие - его копию
+// с заново полученным телом.
+func prepareAttempt(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("cannot replay request body: %w", err)
+	}
+
+	r := req.Clone(req.Context())
+	r.Body = body
+	return r, nil
+}
+
+// isIdempotent сообщает, является ли запрос идемпотентным по RFC 9110
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	// Так же, как net/http, считаем идемпотентными запросы с ключом идемпотентности
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+// discard вычитывает остаток тела ответа и закрывает его,
+// чтобы соединение вернулось в пул
+func discard(resp *http.Response) {
+	const maxDrain = 4 << 10
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrain))
+	resp.Body.Close()
+}
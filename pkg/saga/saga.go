@@ -0,0 +1,288 @@
+// Package saga реализует оркестратор саг - многошаговых процессов, которые
+// нельзя выполнить в одной транзакции (резерв товара -> оплата -> доставка).
+// Шаги выполняются по порядку; если действие шага не удалось, для уже
+// выполненных шагов в обратном порядке вызываются компенсации.
+// Прогресс сохраняется в Store после каждого шага, поэтому прерванную
+// сагу можно продолжить после перезапуска через Resume или ResumeAll.
+//
+// Действие шага может быть выполнено повторно, если процесс упал после
+// действия, но до сохранения прогресса. Поэтому действия и компенсации
+// должны быть идемпотентными.
+//
+// Сагу одновременно выполняет только один исполнитель: Run создает запись
+// атомарно, а Resume сначала захватывает сагу на срок WithLease. Срок
+// продлевается при сохранении прогресса после каждого шага и освобождается,
+// когда исполнитель останавливается. Сага упавшего процесса становится
+// доступной для Resume после истечения срока.
+package saga
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+// defaultLease - срок владения сагой по умолчанию
+const defaultLease = time.Minute
+
+// ErrAlreadyStarted - сага с таким идентификатором уже запускалась
+var ErrAlreadyStarted = errors.New("saga: already started")
+
+// Step - шаг саги над состоянием типа T.
+// Изменения состояния в Action сохраняются и доступны следующим шагам и компенсациям.
+type Step[T any] struct {
+	Name       string
+	Action     func(ctx context.Context, state *T) error
+	Compensate func(ctx context.Context, state *T) error // nil - шаг не требует компенсации
+}
+
+// StepError - действие шага завершилось ошибкой, и сага была компенсирована
+// (полностью или до первой неудачной компенсации)
+type StepError struct {
+	Step string // Шаг, действие которого не удалось
+	Err  error  // Ошибка действия
+
+	CompensationStep string // Шаг, компенсация которого не удалась
+	CompensationErr  error  // Ошибка компенсации, nil если все компенсации выполнены
+}
+
+// Error возвращает описание ошибки шага и компенсации
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("saga step %s failed: %s", e.Step, e.Err)
+	if e.CompensationErr != nil {
+		msg += fmt.Sprintf("; compensation of step %s failed: %s", e.CompensationStep, e.CompensationErr)
+	}
+	return msg
+}
+
+// Unwrap возвращает ошибки действия и компенсации
+func (e *StepError) Unwrap() []error {
+	if e.CompensationErr == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.CompensationErr}
+}
+
+// Saga - определение саги: имя, шаги и хранилище прогресса
+type Saga[T any] struct {
+	name  string
+	steps []Step[T]
+	store Store
+	lease time.Duration
+	clock clock.Clock
+}
+
+// New создает определение саги. Имя отличает саги разных типов в общем хранилище
+func New[T any](name string, store Store, steps ...Step[T]) *Saga[T] {
+	return &Saga[T]{name: name, steps: steps, store: store, lease: defaultLease, clock: clock.New()}
+}
+
+// WithLease задает срок, на который исполнитель захватывает сагу (по умолчанию 1m).
+// Срок должен превышать длительность самого долгого шага, иначе сагу
+// во время шага может забрать другой исполнитель.
+func (s *Saga[T]) WithLease(d time.Duration) *Saga[T] {
+	s.lease = d
+	return s
+}
+
+// WithClock задает источник времени для сроков владения
+func (s *Saga[T]) WithClock(c clock.Clock) *Saga[T] {
+	s.clock = clock.OrDefault(c)
+	return s
+}
+
+// Run запускает новую сагу с идентификатором id и начальным состоянием state.
+// Возвращает итоговое состояние. Если действие шага не удалось,
+// возвращается *StepError после выполнения компенсаций.
+// Если сага с таким id уже создана, возвращает ErrAlreadyStarted.
+func (s *Saga[T]) Run(ctx context.Context, id string, state T) (T, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return state, err
+	}
+
+	rec := Record{ID: id, Name: s.name, Status: StatusRunning, Owner: owner}
+	if err := s.encode(&rec, state); err != nil {
+		return state, err
+	}
+	if err := s.store.Create(ctx, rec); err != nil {
+		if errors.Is(err, ErrAlreadyStarted) {
+			return state, ErrAlreadyStarted
+		}
+		return state, fmt.Errorf("cannot create saga %s: %w", id, err)
+	}
+	return s.run(ctx, &rec, state)
+}
+
+// Resume продолжает сохраненную сагу с того места, где она была прервана.
+// Для завершенной саги шаги не выполняются: возвращается ее итоговое
+// состояние и, если сага была компенсирована, *StepError.
+// Если сагу выполняет другой исполнитель, возвращает ErrClaimed.
+func (s *Saga[T]) Resume(ctx context.Context, id string) (T, error) {
+	var state T
+
+	rec, err := s.store.Load(ctx, id)
+	if err != nil {
+		return state, fmt.Errorf("cannot load saga %s: %w", id, err)
+	}
+	if rec.Name != s.name {
+		return state, fmt.Errorf("saga %s belongs to %q, not %q", id, rec.Name, s.name)
+	}
+
+	if !rec.Status.Done() {
+		owner, err := newOwner()
+		if err != nil {
+			return state, err
+		}
+		now := s.clock.Now()
+		if rec, err = s.store.Claim(ctx, id, owner, now.Add(s.lease), now); err != nil {
+			return state, fmt.Errorf("cannot claim saga %s: %w", id, err)
+		}
+	}
+
+	if err := json.Unmarshal(rec.Data, &state); err != nil {
+		return state, fmt.Errorf("cannot decode saga %s state: %w", id, err)
+	}
+	return s.run(ctx, &rec, state)
+}
+
+// ResumeAll продолжает все незавершенные саги этого определения,
+// например при старте сервиса. Саги, которые выполняют другие исполнители,
+// пропускаются. Ошибки отдельных саг объединяются.
+func (s *Saga[T]) ResumeAll(ctx context.Context) error {
+	recs, err := s.store.ListUnfinished(ctx, s.name)
+	if err != nil {
+		return fmt.Errorf("cannot list unfinished sagas: %w", err)
+	}
+
+	var errs []error
+	for _, rec := range recs {
+		if _, err := s.Resume(ctx, rec.ID); err != nil && !errors.Is(err, ErrClaimed) {
+			errs = append(errs, fmt.Errorf("saga %s: %w", rec.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// run выполняет захваченную сагу и освобождает ее, если она не завершилась,
+// чтобы Resume мог продолжить ее сразу, не дожидаясь истечения срока
+func (s *Saga[T]) run(ctx context.Context, rec *Record, state T) (T, error) {
+	state, err := s.execute(ctx, rec, state)
+	if rec.Status.Done() || errors.Is(err, ErrLeaseLost) {
+		return state, err
+	}
+
+	// Если освободить не удалось, сага станет доступной по истечении срока
+	released := *rec
+	released.LeaseUntil = time.Time{}
+	_ = s.store.Save(context.WithoutCancel(ctx), released)
+	return state, err
+}
+
+// execute выполняет сагу от сохраненной позиции
+func (s *Saga[T]) execute(ctx context.Context, rec *Record, state T) (T, error) {
+	switch rec.Status {
+	case StatusRunning:
+		return s.forward(ctx, rec, state)
+	case StatusCompensating:
+		return s.backward(ctx, rec, state, errors.New(rec.Error))
+	case StatusCompleted:
+		return state, nil
+	case StatusCompensated:
+		return state, &StepError{Step: rec.FailedStep, Err: errors.New(rec.Error)}
+	default:
+		return state, fmt.Errorf("saga %s has unknown status %q", rec.ID, rec.Status)
+	}
+}
+
+// forward выполняет действия шагов, начиная с rec.Step
+func (s *Saga[T]) forward(ctx context.Context, rec *Record, state T) (T, error) {
+	for rec.Step < len(s.steps) {
+		step := s.steps[rec.Step]
+		if err := step.Action(ctx, &state); err != nil {
+			// Неудачный шаг не выполнен, компенсируем только предыдущие
+			rec.Status = StatusCompensating
+			rec.FailedStep = step.Name
+			rec.Error = err.Error()
+			if saveErr := s.save(ctx, rec, state); saveErr != nil {
+				return state, errors.Join(&StepError{Step: step.Name, Err: err}, saveErr)
+			}
+			return s.backward(ctx, rec, state, err)
+		}
+
+		rec.Step++
+		if err := s.save(ctx, rec, state); err != nil {
+			return state, err
+		}
+	}
+
+	rec.Status = StatusCompleted
+	if err := s.save(ctx, rec, state); err != nil {
+		return state, err
+	}
+	return state, nil
+}
+
+// backward выполняет компенсации выполненных шагов в обратном порядке.
+// Неудачная компенсация останавливает сагу в StatusCompensating,
+// чтобы Resume мог повторить ее позже
+func (s *Saga[T]) backward(ctx context.Context, rec *Record, state T, cause error) (T, error) {
+	failed := rec.FailedStep
+
+	for rec.Step > 0 {
+		step := s.steps[rec.Step-1]
+		if step.Compensate != nil {
+			if err := step.Compensate(ctx, &state); err != nil {
+				return state, &StepError{Step: failed, Err: cause, CompensationStep: step.Name, CompensationErr: err}
+			}
+		}
+
+		rec.Step--
+		if err := s.save(ctx, rec, state); err != nil {
+			return state, errors.Join(&StepError{Step: failed, Err: cause}, err)
+		}
+	}
+
+	rec.Status = StatusCompensated
+	if err := s.save(ctx, rec, state); err != nil {
+		return state, errors.Join(&StepError{Step: failed, Err: cause}, err)
+	}
+	return state, &StepError{Step: failed, Err: cause}
+}
+
+// save сохраняет состояние и прогресс, продлевая срок владения сагой
+func (s *Saga[T]) save(ctx context.Context, rec *Record, state T) error {
+	if err := s.encode(rec, state); err != nil {
+		return err
+	}
+	if err := s.store.Save(ctx, *rec); err != nil {
+		return fmt.Errorf("cannot save saga %s: %w", rec.ID, err)
+	}
+	return nil
+}
+
+// encode сериализует состояние в запись и продлевает срок владения
+func (s *Saga[T]) encode(rec *Record, state T) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("cannot encode saga %s state: %w", rec.ID, err)
+	}
+	rec.Data = data
+	rec.LeaseUntil = s.clock.Now().Add(s.lease)
+	return nil
+}
+
+// newOwner генерирует идентификатор исполнителя саги
+func newOwner() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot generate saga owner: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
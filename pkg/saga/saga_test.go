@@ -0,0 +1,275 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+type order struct {
+	Reserved bool
+	Paid     bool
+	Log      []string
+}
+
+// orderSteps возвращает шаги саги заказа; failAt - шаг, действие которого завершится ошибкой
+func orderSteps(failAt string, actionErr error) []Step[order] {
+	step := func(name string) Step[order] {
+		return Step[order]{
+			Name: name,
+			Action: func(_ context.Context, o *order) error {
+				if name == failAt {
+					return actionErr
+				}
+				o.Log = append(o.Log, "do "+name)
+				return nil
+			},
+			Compensate: func(_ context.Context, o *order) error {
+				o.Log = append(o.Log, "undo "+name)
+				return nil
+			},
+		}
+	}
+	return []Step[order]{step("reserve"), step("pay"), step("ship")}
+}
+
+func TestSaga_Run_Completes(t *testing.T) {
+	store := NewMemoryStore()
+	s := New("order", store, orderSteps("", nil)...)
+
+	state, err := s.Run(context.Background(), "o-1", order{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"do reserve", "do pay", "do ship"}; !slices.Equal(state.Log, want) {
+		t.Errorf("expected %v, got %v", want, state.Log)
+	}
+
+	rec, err := store.Load(context.Background(), "o-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Status != StatusCompleted || rec.Step != 3 {
+		t.Errorf("expected completed at step 3, got %s at %d", rec.Status, rec.Step)
+	}
+
+	if _, err := s.Run(context.Background(), "o-1", order{}); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
+}
+
+func TestSaga_Run_CompensatesInReverseOrder(t *testing.T) {
+	store := NewMemoryStore()
+	failure := errors.New("no stock")
+	s := New("order", store, orderSteps("ship", failure)...)
+
+	state, err := s.Run(context.Background(), "o-1", order{})
+
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Step != "ship" || stepErr.CompensationErr != nil {
+		t.Fatalf("expected StepError for ship, got %v", err)
+	}
+	if !errors.Is(err, failure) {
+		t.Errorf("expected error to wrap action failure, got %v", err)
+	}
+	if want := []string{"do reserve", "do pay", "undo pay", "undo reserve"}; !slices.Equal(state.Log, want) {
+		t.Errorf("expected %v, got %v", want, state.Log)
+	}
+
+	rec, _ := store.Load(context.Background(), "o-1")
+	if rec.Status != StatusCompensated || rec.FailedStep != "ship" || rec.Error != "no stock" {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+
+	// Повторный Resume завершенной саги ничего не выполняет
+	state, err = s.Resume(context.Background(), "o-1")
+	if !errors.As(err, &stepErr) || stepErr.Step != "ship" {
+		t.Errorf("expected StepError for ship, got %v", err)
+	}
+	if len(state.Log) != 4 {
+		t.Errorf("expected saved state, got %v", state.Log)
+	}
+}
+
+func TestSaga_Resume_RetriesFailedCompensation(t *testing.T) {
+	store := NewMemoryStore()
+	refundFailure := errors.New("payment gateway unavailable")
+	refundFails := true
+
+	steps := orderSteps("ship", errors.New("no courier"))
+	steps[1].Compensate = func(_ context.Context, o *order) error {
+		if refundFails {
+			return refundFailure
+		}
+		o.Log = append(o.Log, "undo pay")
+		return nil
+	}
+	s := New("order", store, steps...)
+
+	_, err := s.Run(context.Background(), "o-1", order{})
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.CompensationStep != "pay" || !errors.Is(err, refundFailure) {
+		t.Fatalf("expected failed compensation of pay, got %v", err)
+	}
+
+	rec, _ := store.Load(context.Background(), "o-1")
+	if rec.Status != StatusCompensating || rec.Step != 2 {
+		t.Fatalf("expected compensating with 2 steps left, got %s with %d", rec.Status, rec.Step)
+	}
+
+	refundFails = false
+	state, err := s.Resume(context.Background(), "o-1")
+	if !errors.As(err, &stepErr) || stepErr.Step != "ship" || stepErr.CompensationErr != nil {
+		t.Fatalf("expected compensated saga, got %v", err)
+	}
+	if want := []string{"do reserve", "do pay", "undo pay", "undo reserve"}; !slices.Equal(state.Log, want) {
+		t.Errorf("expected %v, got %v", want, state.Log)
+	}
+}
+
+// crashingStore имитирует падение процесса: Save завершается ошибкой после limit вызовов
+type crashingStore struct {
+	*MemoryStore
+	limit int
+}
+
+func (s *crashingStore) Save(ctx context.Context, rec Record) error {
+	if s.limit == 0 {
+		return errors.New("crash")
+	}
+	s.limit--
+	return s.MemoryStore.Save(ctx, rec)
+}
+
+func TestSaga_ResumeAll_ContinuesAfterCrash(t *testing.T) {
+	mem := NewMemoryStore()
+	clk := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	calls := map[string]int{}
+	steps := orderSteps("", nil)
+	for i := range steps {
+		action := steps[i].Action
+		steps[i].Action = func(ctx context.Context, o *order) error {
+			calls[steps[i].Name]++
+			return action(ctx, o)
+		}
+	}
+
+	// Прогресс после reserve сохранен, после pay - нет
+	crashing := New("order", &crashingStore{MemoryStore: mem, limit: 1}, steps...).WithClock(clk)
+	if _, err := crashing.Run(context.Background(), "o-1", order{}); err == nil {
+		t.Fatal("expected crash error")
+	}
+	// Саги другого типа не продолжаются
+	if err := mem.Create(context.Background(), Record{ID: "x-1", Name: "other", Status: StatusRunning}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := New("order", mem, steps...).WithClock(clk)
+
+	// Пока срок владения упавшего исполнителя не истек, сага пропускается
+	if err := s.ResumeAll(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls["pay"] != 1 {
+		t.Fatalf("saga must not be resumed before the lease expires, calls: %v", calls)
+	}
+
+	clk.Advance(defaultLease)
+	if err := s.ResumeAll(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec, _ := mem.Load(context.Background(), "o-1")
+	if rec.Status != StatusCompleted {
+		t.Errorf("expected completed, got %s", rec.Status)
+	}
+	// pay выполняется повторно, так как его прогресс не был сохранен
+	if calls["reserve"] != 1 || calls["pay"] != 2 || calls["ship"] != 1 {
+		t.Errorf("unexpected action calls: %v", calls)
+	}
+
+	unfinished, _ := mem.ListUnfinished(context.Background(), "order")
+	if len(unfinished) != 0 {
+		t.Errorf("expected no unfinished sagas, got %v", unfinished)
+	}
+}
+
+func TestSaga_Resume_ClaimedByAnotherExecutor(t *testing.T) {
+	store := NewMemoryStore()
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	steps := orderSteps("", nil)
+	steps[0].Action = func(_ context.Context, o *order) error {
+		close(started)
+		<-release
+		return nil
+	}
+	s := New("order", store, steps...)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Run(context.Background(), "o-1", order{})
+		done <- err
+	}()
+	<-started
+
+	// Сагу выполняет первый исполнитель - второй не может ее ни создать, ни продолжить
+	if _, err := s.Run(context.Background(), "o-1", order{}); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
+	if _, err := s.Resume(context.Background(), "o-1"); !errors.Is(err, ErrClaimed) {
+		t.Errorf("expected ErrClaimed, got %v", err)
+	}
+	if err := s.ResumeAll(context.Background()); err != nil {
+		t.Errorf("claimed sagas must be skipped, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSaga_LeaseLost(t *testing.T) {
+	store := NewMemoryStore()
+	clk := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+	// Шаг длится дольше срока владения, и сагу забирает другой исполнитель
+	steps := orderSteps("", nil)
+	steps[0].Action = func(ctx context.Context, o *order) error {
+		clk.Advance(2 * time.Second)
+		if _, err := store.Claim(ctx, "o-1", "other", clk.Now().Add(time.Minute), clk.Now()); err != nil {
+			t.Fatalf("unexpected claim error: %v", err)
+		}
+		return nil
+	}
+	s := New("order", store, steps...).WithLease(time.Second).WithClock(clk)
+
+	if _, err := s.Run(context.Background(), "o-1", order{}); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost, got %v", err)
+	}
+
+	rec, _ := store.Load(context.Background(), "o-1")
+	if rec.Owner != "other" || rec.Step != 0 {
+		t.Errorf("progress of the lost saga must not be saved: %+v", rec)
+	}
+}
+
+func TestSaga_Resume_WrongName(t *testing.T) {
+	store := NewMemoryStore()
+	if _, err := New("order", store, orderSteps("", nil)...).Run(context.Background(), "o-1", order{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := New("refund", store, orderSteps("", nil)...).Resume(context.Background(), "o-1"); err == nil {
+		t.Error("expected error for saga of another type")
+	}
+	if _, err := New[order]("order", store).Resume(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package saga
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+// defaultTable - имя таблицы саг по умолчанию
+const defaultTable = "sagas"
+
+// SQLStore - хранилище саг в таблице БД.
+// Запросы выполняются через gotrxmanager.Querier: Save внутри Do менеджера
+// попадает в ту же транзакцию, что и изменения шага в этой БД.
+// Чтение всегда идет в основную БД, даже если менеджеру заданы реплики.
+// Сроки владения сравниваются по часам исполнителей, поэтому часы
+// процессов должны быть синхронизированы с точностью много меньше срока.
+//
+// Схема таблицы (PostgreSQL):
+//
+//	CREATE TABLE sagas (
+//		id          TEXT PRIMARY KEY,
+//		name        TEXT        NOT NULL,
+//		status      TEXT        NOT NULL,
+//		step        INT         NOT NULL,
+//		data        BYTEA,
+//		failed_step TEXT        NOT NULL DEFAULT '',
+//		error       TEXT        NOT NULL DEFAULT '',
+//		owner       TEXT        NOT NULL DEFAULT '',
+//		lease_until TIMESTAMPTZ,
+//		updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+//	);
+//	CREATE INDEX sagas_unfinished_idx ON sagas (name) WHERE status IN ('running', 'compensating');
+type SQLStore struct {
+	q *gotrxmanager.Querier
+
+	createQuery string
+	saveQuery   string
+	claimQuery  string
+	loadQuery   string
+	listQuery   string
+}
+
+// recordColumns - колонки записи саги в порядке scanRecord
+const recordColumns = "id, name, status, step, data, failed_step, error, owner, lease_until"
+
+// NewSQLStore создает хранилище саг в таблице table (по умолчанию "sagas")
+func NewSQLStore(trm *gotrxmanager.TransactionManager, table string) *SQLStore {
+	if table == "" {
+		table = defaultTable
+	}
+
+	return &SQLStore{
+		q: gotrxmanager.NewQuerier(trm),
+		createQuery: fmt.Sprintf("INSERT INTO %s (%s, updated_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, CURRENT_TIMESTAMP) ON CONFLICT (id) DO NOTHING", table, recordColumns),
+		saveQuery: fmt.Sprintf("UPDATE %s SET name = $2, status = $3, step = $4, data = $5, failed_step = $6, error = $7, "+
+			"lease_until = $9, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND owner = $8", table),
+		claimQuery: fmt.Sprintf("UPDATE %s SET owner = $2, lease_until = $3, updated_at = CURRENT_TIMESTAMP "+
+			"WHERE id = $1 AND status IN ('running', 'compensating') AND (lease_until IS NULL OR lease_until <= $4) "+
+			"RETURNING %s", table, recordColumns),
+		loadQuery: fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", recordColumns, table),
+		listQuery: fmt.Sprintf("SELECT %s FROM %s "+
+			"WHERE name = $1 AND status IN ('running', 'compensating') ORDER BY id", recordColumns, table),
+	}
+}
+
+// Create сохраняет новую запись саги или возвращает ErrAlreadyStarted
+func (s *SQLStore) Create(ctx context.Context, rec Record) error {
+	res, err := s.q.ExecContext(ctx, s.createQuery, recordArgs(rec)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlreadyStarted
+	}
+	return nil
+}
+
+// Save обновляет запись саги, если ею владеет rec.Owner, иначе возвращает ErrLeaseLost
+func (s *SQLStore) Save(ctx context.Context, rec Record) error {
+	res, err := s.q.ExecContext(ctx, s.saveQuery, recordArgs(rec)...)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Claim передает сагу исполнителю owner. Возвращает ErrClaimed, если сага
+// не найдена среди доступных для захвата
+func (s *SQLStore) Claim(ctx context.Context, id, owner string, until, now time.Time) (Record, error) {
+	row := s.q.QueryRowContext(ctx, s.claimQuery, id, owner, until, now)
+
+	rec, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrClaimed
+	}
+	return rec, err
+}
+
+// Load возвращает запись саги или ErrNotFound
+func (s *SQLStore) Load(ctx context.Context, id string) (Record, error) {
+	row := s.q.QueryRowContext(ctx, s.loadQuery, id)
+
+	rec, err := scanRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, ErrNotFound
+	}
+	return rec, err
+}
+
+// ListUnfinished возвращает незавершенные саги с именем name
+func (s *SQLStore) ListUnfinished(ctx context.Context, name string) ([]Record, error) {
+	rows, err := s.q.QueryContext(ctx, s.listQuery, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recs []Record
+	for rows.Next() {
+		rec, err := scanRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, rows.Err()
+}
+
+// recordArgs возвращает параметры запросов Create и Save
+func recordArgs(rec Record) []any {
+	var leaseUntil sql.NullTime
+	if !rec.LeaseUntil.IsZero() {
+		leaseUntil = sql.NullTime{Time: rec.LeaseUntil, Valid: true}
+	}
+	return []any{rec.ID, rec.Name, string(rec.Status), rec.Step, rec.Data, rec.FailedStep, rec.Error, rec.Owner, leaseUntil}
+}
+
+// scanRecord читает запись саги из строки результата
+func scanRecord(row interface{ Scan(dest ...any) error }) (Record, error) {
+	var rec Record
+	var status string
+	var leaseUntil sql.NullTime
+	err := row.Scan(&rec.ID, &rec.Name, &status, &rec.Step, &rec.Data, &rec.FailedStep, &rec.Error, &rec.Owner, &leaseUntil)
+	rec.Status = Status(status)
+	rec.LeaseUntil = leaseUntil.Time
+	return rec, err
+}
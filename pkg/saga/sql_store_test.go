@@ -0,0 +1,157 @@
+package saga
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+var sagaColumns = []string{"id", "name", "status", "step", "data", "failed_step", "error", "owner", "lease_until"}
+
+func newSQLStore(t *testing.T, table string) (*SQLStore, *gotrxmanager.TransactionManager, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	trm := gotrxmanager.NewTransactionManager(db)
+	return NewSQLStore(trm, table), trm, mock
+}
+
+func TestSQLStore(t *testing.T) {
+	store, _, mock := newSQLStore(t, "")
+	lease := time.Date(2024, time.January, 1, 0, 1, 0, 0, time.UTC)
+	rec := Record{ID: "o-1", Name: "order", Status: StatusRunning, Step: 1, Data: []byte(`{}`), Owner: "a", LeaseUntil: lease}
+	args := []driver.Value{"o-1", "order", "running", 1, []byte(`{}`), "", "", "a", lease}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO sagas (id, name, status, step, data, failed_step, error, owner, lease_until, updated_at)")).
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("ON CONFLICT (id) DO NOTHING")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE sagas SET name = $2")).
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("WHERE id = $1 AND owner = $8")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, name, status, step, data, failed_step, error, owner, lease_until FROM sagas WHERE id = $1")).
+		WithArgs("o-1").
+		WillReturnRows(sqlmock.NewRows(sagaColumns).AddRow("o-1", "order", "running", 1, []byte(`{}`), "", "", "a", lease))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM sagas WHERE id = $1")).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(sagaColumns))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE name = $1 AND status IN ('running', 'compensating') ORDER BY id")).
+		WithArgs("order").
+		WillReturnRows(sqlmock.NewRows(sagaColumns).
+			AddRow("o-1", "order", "running", 1, []byte(`{}`), "", "", "a", lease).
+			AddRow("o-2", "order", "compensating", 2, []byte(`{}`), "pay", "declined", "", nil))
+
+	ctx := context.Background()
+	if err := store.Create(ctx, rec); err != nil {
+		t.Fatalf("unexpected create error: %v", err)
+	}
+	if err := store.Create(ctx, rec); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
+	if err := store.Save(ctx, rec); err != nil {
+		t.Fatalf("unexpected save error: %v", err)
+	}
+	if err := store.Save(ctx, rec); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost, got %v", err)
+	}
+
+	loaded, err := store.Load(ctx, "o-1")
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+	if loaded.ID != rec.ID || loaded.Status != rec.Status || loaded.Step != rec.Step || loaded.Owner != "a" || !loaded.LeaseUntil.Equal(lease) {
+		t.Errorf("expected %+v, got %+v", rec, loaded)
+	}
+
+	if _, err := store.Load(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	recs, err := store.ListUnfinished(ctx, "order")
+	if err != nil {
+		t.Fatalf("unexpected list error: %v", err)
+	}
+	if len(recs) != 2 || recs[1].Status != StatusCompensating || recs[1].FailedStep != "pay" || !recs[1].LeaseUntil.IsZero() {
+		t.Errorf("unexpected records: %+v", recs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLStore_Claim(t *testing.T) {
+	store, _, mock := newSQLStore(t, "")
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	until := now.Add(time.Minute)
+	claim := regexp.QuoteMeta("UPDATE sagas SET owner = $2, lease_until = $3") + ".*" +
+		regexp.QuoteMeta("(lease_until IS NULL OR lease_until <= $4) RETURNING")
+
+	mock.ExpectQuery(claim).
+		WithArgs("o-1", "b", until, now).
+		WillReturnRows(sqlmock.NewRows(sagaColumns).AddRow("o-1", "order", "running", 2, []byte(`{}`), "", "", "b", until))
+	mock.ExpectQuery(claim).
+		WithArgs("o-2", "b", until, now).
+		WillReturnRows(sqlmock.NewRows(sagaColumns))
+
+	rec, err := store.Claim(context.Background(), "o-1", "b", until, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Owner != "b" || rec.Step != 2 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+
+	if _, err := store.Claim(context.Background(), "o-2", "b", until, now); !errors.Is(err, ErrClaimed) {
+		t.Errorf("expected ErrClaimed, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLStore_SaveInTransaction(t *testing.T) {
+	store, trm, mock := newSQLStore(t, "order_sagas")
+
+	// Прогресс саги сохраняется в транзакции шага и откатывается вместе с ней
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE stock")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE order_sagas")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	failure := errors.New("failed")
+	err := gotrxmanager.DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		tx, err := gotrxmanager.TxFromContext(ctx, trm)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE stock SET reserved = reserved + 1"); err != nil {
+			return err
+		}
+		if err := store.Save(ctx, Record{ID: "o-1", Name: "order", Status: StatusRunning, Step: 1}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("expected failure, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,160 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound - сага с таким идентификатором не сохранена
+	ErrNotFound = errors.New("saga: not found")
+
+	// ErrClaimed - сага выполняется другим исполнителем или уже завершена
+	ErrClaimed = errors.New("saga: claimed by another executor")
+
+	// ErrLeaseLost - срок владения сагой истек, и ее забрал другой исполнитель
+	ErrLeaseLost = errors.New("saga: lease lost")
+)
+
+// Status - состояние выполнения саги
+type Status string
+
+const (
+	StatusRunning      Status = "running"      // Выполняются действия шагов
+	StatusCompensating Status = "compensating" // Действие шага не удалось, выполняются компенсации
+	StatusCompleted    Status = "completed"    // Все шаги выполнены
+	StatusCompensated  Status = "compensated"  // Все выполненные шаги компенсированы
+)
+
+// Done сообщает, завершена ли сага
+func (s Status) Done() bool {
+	return s == StatusCompleted || s == StatusCompensated
+}
+
+// Record - сохраненный прогресс саги
+type Record struct {
+	ID     string
+	Name   string // Имя определения саги
+	Status Status
+	// Для StatusRunning - индекс следующего шага для выполнения,
+	// для StatusCompensating - количество еще не компенсированных шагов
+	Step int
+	Data []byte // Состояние саги в JSON
+
+	FailedStep string // Шаг, действие которого не удалось
+	Error      string // Ошибка этого действия
+
+	Owner      string    // Исполнитель, владеющий сагой
+	LeaseUntil time.Time // Срок владения, после него сагу может забрать другой исполнитель
+}
+
+// Store сохраняет прогресс саг, чтобы продолжить их после сбоя.
+// Create и Claim должны быть атомарными: они гарантируют, что сагу
+// выполняет только один исполнитель.
+type Store interface {
+	// Create сохраняет новую запись или возвращает ErrAlreadyStarted,
+	// если запись с таким идентификатором уже есть
+	Create(ctx context.Context, rec Record) error
+	// Save обновляет запись саги, если ею все еще владеет rec.Owner,
+	// иначе возвращает ErrLeaseLost
+	Save(ctx context.Context, rec Record) error
+	// Claim передает незавершенную сагу исполнителю owner до until, если срок
+	// владения предыдущего исполнителя истек к моменту now. Возвращает
+	// обновленную запись, ErrClaimed или ErrNotFound
+	Claim(ctx context.Context, id, owner string, until, now time.Time) (Record, error)
+	// Load возвращает запись саги или ErrNotFound
+	Load(ctx context.Context, id string) (Record, error)
+	// ListUnfinished возвращает незавершенные саги с именем name
+	ListUnfinished(ctx context.Context, name string) ([]Record, error)
+}
+
+// MemoryStore - хранилище саг в памяти процесса, для тестов и
+// саг, которые не нужно продолжать после перезапуска
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryStore создает пустое хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+// Create сохраняет копию новой записи
+func (s *MemoryStore) Create(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.records[rec.ID]; ok {
+		return ErrAlreadyStarted
+	}
+	rec.Data = slices.Clone(rec.Data)
+	s.records[rec.ID] = rec
+	return nil
+}
+
+// Save сохраняет копию записи
+func (s *MemoryStore) Save(_ context.Context, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.records[rec.ID]; !ok || stored.Owner != rec.Owner {
+		return ErrLeaseLost
+	}
+	rec.Data = slices.Clone(rec.Data)
+	s.records[rec.ID] = rec
+	return nil
+}
+
+// Claim передает сагу исполнителю owner
+func (s *MemoryStore) Claim(_ context.Context, id, owner string, until, now time.Time) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[id]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	if rec.Status.Done() || rec.LeaseUntil.After(now) {
+		return Record{}, ErrClaimed
+	}
+
+	rec.Owner = owner
+	rec.LeaseUntil = until
+	s.records[id] = rec
+	rec.Data = slices.Clone(rec.Data)
+	return rec, nil
+}
+
+// Load возвращает копию записи
+func (s *MemoryStore) Load(_ context.Context, id string) (Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.records[id]
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	rec.Data = slices.Clone(rec.Data)
+	return rec, nil
+}
+
+// ListUnfinished возвращает незавершенные саги, упорядоченные по идентификатору
+func (s *MemoryStore) ListUnfinished(_ context.Context, name string) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recs []Record
+	for _, rec := range s.records {
+		if rec.Name == name && !rec.Status.Done() {
+			rec.Data = slices.Clone(rec.Data)
+			recs = append(recs, rec)
+		}
+	}
+	slices.SortFunc(recs, func(a, b Record) int { return strings.Compare(a.ID, b.ID) })
+	return recs, nil
+}
@@ -0,0 +1,89 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	if _, err := store.Load(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	data := []byte(`{"n":1}`)
+	for _, rec := range []Record{
+		{ID: "c", Name: "order", Status: StatusCompensating, Data: data},
+		{ID: "a", Name: "order", Status: StatusRunning, Data: data},
+		{ID: "b", Name: "order", Status: StatusCompleted},
+		{ID: "d", Name: "refund", Status: StatusRunning},
+	} {
+		if err := store.Create(ctx, rec); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := store.Create(ctx, Record{ID: "a"}); !errors.Is(err, ErrAlreadyStarted) {
+		t.Errorf("expected ErrAlreadyStarted, got %v", err)
+	}
+
+	// Хранилище не разделяет данные с вызывающим кодом
+	data[0] = 'x'
+	rec, err := store.Load(ctx, "a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(rec.Data) != `{"n":1}` {
+		t.Errorf("expected stored copy of data, got %s", rec.Data)
+	}
+
+	recs, err := store.ListUnfinished(ctx, "order")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recs) != 2 || recs[0].ID != "a" || recs[1].ID != "c" {
+		t.Errorf("expected unfinished a and c, got %+v", recs)
+	}
+}
+
+func TestMemoryStore_Claim(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := store.Create(ctx, Record{ID: "a", Status: StatusRunning, Owner: "first", LeaseUntil: now.Add(time.Minute)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Create(ctx, Record{ID: "b", Status: StatusCompleted}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := store.Claim(ctx, "a", "second", now.Add(time.Hour), now); !errors.Is(err, ErrClaimed) {
+		t.Errorf("expected ErrClaimed before lease expiry, got %v", err)
+	}
+	if _, err := store.Claim(ctx, "b", "second", now.Add(time.Hour), now); !errors.Is(err, ErrClaimed) {
+		t.Errorf("expected ErrClaimed for completed saga, got %v", err)
+	}
+	if _, err := store.Claim(ctx, "missing", "second", now.Add(time.Hour), now); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	rec, err := store.Claim(ctx, "a", "second", now.Add(2*time.Minute), now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rec.Owner != "second" {
+		t.Errorf("expected owner second, got %s", rec.Owner)
+	}
+
+	// Прежний владелец больше не может сохранять прогресс
+	if err := store.Save(ctx, Record{ID: "a", Status: StatusRunning, Step: 1, Owner: "first"}); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("expected ErrLeaseLost, got %v", err)
+	}
+	if err := store.Save(ctx, Record{ID: "a", Status: StatusRunning, Step: 1, Owner: "second"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,462 @@
+package gotrxmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+var (
+	// ErrPartialCommit - координированный коммит не удался, и часть ресурсов
+	// осталась закоммиченной или в неизвестном состоянии. Подробности - в CommitReport
+	ErrPartialCommit = errors.New("coordinated commit left resources partially committed")
+
+	// ErrNoCoordinator возвращается Compensate вне Coordinator.Do
+	ErrNoCoordinator = errors.New("no coordinated transaction in context")
+)
+
+// coordinationKey - ключ состояния Coordinator.Do в контексте
+var coordinationKey = &trxManagerKey{name: "coordination"}
+
+// CoordinatorOption - настройка координатора
+type CoordinatorOption func(*Coordinator)
+
+// WithTwoPhaseCommit включает подготовку транзакций через PREPARE TRANSACTION
+// (PostgreSQL, требуется max_prepared_transactions > 0). Коммит начинается,
+// только когда подготовлены все ресурсы, поэтому ошибка подготовки
+// откатывает все транзакции без компенсаций.
+func WithTwoPhaseCommit() CoordinatorOption {
+	return func(c *Coordinator) {
+		c.twoPhase = true
+	}
+}
+
+// NewCoordinator - конструктор координатора транзакций
+func NewCoordinator(opts ...CoordinatorOption) *Coordinator {
+	c := &Coordinator{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Register добавляет БД менеджера trm в координатор под именем name.
+// Порядок регистрации задает порядок коммита. Менеджер должен быть создан
+// через NewTransactionManager и зарегистрирован один раз.
+// Менеджер с WithReadOnly по умолчанию не подходит: его транзакции могли бы
+// открыться на реплике, а координатор изменяет данные в основной БД.
+func (c *Coordinator) Register(name string, trm *TransactionManager) *Coordinator {
+	b := sqlBeginnerOf(trm, "Coordinator")
+	if trm.defaults.txOptions.ReadOnly {
+		panic(fmt.Sprintf("gotrxmanager: Coordinator requires a read-write manager, %s is read-only", name))
+	}
+	if c.twoPhase && b.outer != nil {
+		panic(fmt.Sprintf("gotrxmanager: two-phase Coordinator cannot use savepoint manager %s", name))
+	}
+
+	c.resources = append(c.resources, coordinatedResource{
+		name: name,
+		trm:  trm,
+		db:   b.db,
+	})
+	return c
+}
+
+// ResourceStatus - итоговое состояние транзакции ресурса
+type ResourceStatus int
+
+const (
+	ResourceNotStarted         ResourceStatus = iota // Транзакция не открывалась
+	ResourceRolledBack                               // Транзакция откачена
+	ResourceCommitted                                // Транзакция закоммичена
+	ResourceCommitFailed                             // Ошибка коммита, результат транзакции неизвестен
+	ResourceCompensated                              // Транзакция закоммичена, затем отменена компенсациями
+	ResourceCompensationFailed                       // Транзакция закоммичена, компенсация не удалась
+	ResourceInDoubt                                  // Подготовленная транзакция не завершена, нужен COMMIT/ROLLBACK PREPARED вручную
+)
+
+// String возвращает название состояния
+func (s ResourceStatus) String() string {
+	switch s {
+	case ResourceNotStarted:
+		return "not started"
+	case ResourceRolledBack:
+		return "rolled back"
+	case ResourceCommitted:
+		return "committed"
+	case ResourceCommitFailed:
+		return "commit failed"
+	case ResourceCompensated:
+		return "compensated"
+	case ResourceCompensationFailed:
+		return "compensation failed"
+	case ResourceInDoubt:
+		return "in doubt"
+	default:
+		return fmt.Sprintf("ResourceStatus(%d)", int(s))
+	}
+}
+
+// committed сообщает, были ли изменения ресурса закоммичены в БД
+func (s ResourceStatus) committed() bool {
+	switch s {
+	case ResourceCommitted, ResourceCompensated, ResourceCompensationFailed:
+		return true
+	}
+	return false
+}
+
+// ResourceResult - результат транзакции одного ресурса
+type ResourceResult struct {
+	Name       string
+	Status     ResourceStatus
+	PreparedID string // Идентификатор PREPARE TRANSACTION (при WithTwoPhaseCommit)
+	Err        error  // Ошибка коммита, отката или компенсации
+}
+
+// CommitReport - отчет координатора о судьбе транзакции каждого ресурса
+type CommitReport struct {
+	Resources []ResourceResult // В порядке регистрации
+}
+
+// Committed возвращает имена ресурсов, изменения которых остались в БД
+func (r *CommitReport) Committed() []string {
+	var names []string
+	for _, res := range r.Resources {
+		if res.Status == ResourceCommitted || res.Status == ResourceCompensationFailed {
+			names = append(names, res.Name)
+		}
+	}
+	return names
+}
+
+// String возвращает отчет в виде "orders: committed, billing: commit failed (...)"
+func (r *CommitReport) String() string {
+	parts := make([]string, 0, len(r.Resources))
+	for _, res := range r.Resources {
+		part := res.Name + ": " + res.Status.String()
+		if res.Err != nil {
+			part += " (" + res.Err.Error() + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// partial сообщает, остались ли после сбоя ресурсы с закоммиченными
+// или неизвестными изменениями
+func (r *CommitReport) partial() bool {
+	for _, res := range r.Resources {
+		switch res.Status {
+		case ResourceCommitted, ResourceCommitFailed, ResourceCompensationFailed, ResourceInDoubt:
+			return true
+		}
+	}
+	return false
+}
+
+// coordination - состояние Coordinator.Do, хранящееся в контексте
+type coordination struct {
+	mu            sync.Mutex
+	compensations map[*TransactionManager][]func(ctx context.Context) error
+}
+
+// Compensate регистрирует fn, отменяющую изменения, сделанные в текущем
+// Coordinator.Do в БД менеджера trm. Компенсации выполняются, только если
+// БД trm уже закоммичена, а коммит одного из следующих ресурсов не удался:
+// по ресурсам в обратном порядке коммита, внутри ресурса - в обратном порядке
+// регистрации. fn получает контекст без координируемых транзакций.
+func Compensate(ctx context.Context, trm *TransactionManager, fn func(ctx context.Context) error) error {
+	co, ok := ctx.Value(coordinationKey).(*coordination)
+	if !ok {
+		return ErrNoCoordinator
+	}
+
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	if _, ok := co.compensations[trm]; !ok {
+		return fmt.Errorf("manager is not registered in the coordinator")
+	}
+	co.compensations[trm] = append(co.compensations[trm], fn)
+	return nil
+}
+
+// Do открывает транзакции на всех зарегистрированных БД и выполняет в них f.
+// Внутри f транзакции доступны через TxFromContext и Querier своих менеджеров.
+//
+// Если f завершилась ошибкой, все транзакции откатываются. Иначе они
+// коммитятся по порядку регистрации; если коммит ресурса не удался, следующие
+// ресурсы откатываются, а для уже закоммиченных выполняются компенсации
+// (Compensate). Отчет возвращается всегда, в том числе вместе с ошибкой.
+// Если после сбоя какие-то изменения остались в БД, ошибка оборачивает ErrPartialCommit.
+//
+// Транзакции открываются с настройками менеджеров по умолчанию: каждая
+// учитывается в Metrics и отчете о медленных транзакциях своего менеджера
+// и отслеживается после завершения Do. Время всего Do ограничено наименьшим
+// WithTimeout менеджеров; COMMIT PREPARED и ROLLBACK PREPARED подготовленных
+// транзакций выполняются и после его истечения. Паника f возвращается как *PanicError, если хотя бы
+// у одного менеджера задан WithPanicError. WithRetry и WithPropagation
+// координатором не применяются.
+func (c *Coordinator) Do(ctx context.Context, f func(ctx context.Context) error) (report *CommitReport, err error) {
+	report = &CommitReport{Resources: make([]ResourceResult, len(c.resources))}
+	co := &coordination{compensations: make(map[*TransactionManager][]func(ctx context.Context) error)}
+	for i, r := range c.resources {
+		report.Resources[i].Name = r.name
+		co.compensations[r.trm] = nil
+	}
+
+	// Компенсации и хуки получат исходный контекст без транзакций и таймаута
+	parent := ctx
+	ctx = context.WithValue(ctx, coordinationKey, co)
+	if timeout := c.timeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, ErrTransactionTimeout)
+		defer cancel()
+	}
+
+	caller := callerOutsidePackage()
+	txs := make([]*transaction, 0, len(c.resources))
+	// Учитываем транзакции в метриках менеджеров при любом исходе
+	finishers := make([]func(), 0, len(c.resources))
+	defer func() {
+		for _, finish := range finishers {
+			finish()
+		}
+	}()
+
+	for i, r := range c.resources {
+		s := r.trm.defaults
+		if s.slowThreshold > 0 {
+			s.caller = caller
+		}
+
+		start := clock.OrDefault(s.clock).Now()
+		tx, beginErr := r.trm.beginner.Begin(ctx, s.txOptions)
+		if beginErr != nil {
+			c.rollback(ctx, txs, report, 0)
+			return report, fmt.Errorf("cannot begin transaction on %s: %w", r.name, timeoutError(ctx, beginErr))
+		}
+		t := &transaction{tx: tx}
+		txs = append(txs, t)
+		ctx = r.trm.withTransaction(ctx, t)
+
+		complete := trackCompletion(ctx, s, tx)
+		finishers = append(finishers, func() {
+			complete()
+			if errors.Is(err, ErrTransactionTimeout) {
+				r.trm.metrics.timedOut.Add(1)
+			}
+			r.trm.finish(ctx, s, start, tx, report.Resources[i].Status.committed())
+		})
+	}
+
+	_, p, err := callRecovered(ctx, func(ctx context.Context) (any, error) {
+		return nil, f(ctx)
+	})
+	if p != nil {
+		c.rollback(ctx, txs, report, 0)
+		c.runHooks(parent, txs, report)
+		if !c.panicAsError() {
+			panic(p.Value)
+		}
+		return report, p
+	}
+	for _, t := range txs {
+		if err == nil && t.rollbackOnly {
+			err = ErrRollbackOnly
+		}
+	}
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		err = timeoutError(ctx, err)
+		c.rollback(ctx, txs, report, 0)
+		c.runHooks(parent, txs, report)
+		return report, err
+	}
+
+	if c.twoPhase {
+		err = c.commitPrepared(ctx, parent, txs, co, report)
+	} else {
+		err = c.commit(ctx, parent, txs, co, report)
+	}
+	c.runHooks(parent, txs, report)
+	return report, err
+}
+
+// timeout возвращает наименьший положительный WithTimeout менеджеров
+func (c *Coordinator) timeout() time.Duration {
+	var timeout time.Duration
+	for _, r := range c.resources {
+		if d := r.trm.defaults.timeout; d > 0 && (timeout == 0 || d < timeout) {
+			timeout = d
+		}
+	}
+	return timeout
+}
+
+// panicAsError сообщает, задан ли WithPanicError хотя бы у одного менеджера
+func (c *Coordinator) panicAsError() bool {
+	for _, r := range c.resources {
+		if r.trm.defaults.panicAsError {
+			return true
+		}
+	}
+	return false
+}
+
+// commit коммитит транзакции по порядку
+func (c *Coordinator) commit(ctx, parent context.Context, txs []*transaction, co *coordination, report *CommitReport) error {
+	for i, t := range txs {
+		if err := t.tx.Commit(ctx); err != nil {
+			report.Resources[i].Status = ResourceCommitFailed
+			report.Resources[i].Err = err
+
+			c.rollback(ctx, txs, report, i+1)
+			c.compensate(parent, co, report, i)
+			return c.commitError(report, i, err)
+		}
+		report.Resources[i].Status = ResourceCommitted
+	}
+	return nil
+}
+
+// commitPrepared подготавливает все транзакции и затем коммитит их по порядку
+func (c *Coordinator) commitPrepared(ctx, parent context.Context, txs []*transaction, co *coordination, report *CommitReport) error {
+	gid, err := newPreparedID()
+	if err != nil {
+		c.rollback(ctx, txs, report, 0)
+		return err
+	}
+
+	for i, t := range txs {
+		id := fmt.Sprintf("%s_%d", gid, i)
+		if err := prepare(ctx, t.tx.(*SQLTx), id); err != nil {
+			// Неподготовленные транзакции откатываются как обычные
+			c.rollback(ctx, txs, report, i)
+			report.Resources[i].Err = errors.Join(err, report.Resources[i].Err)
+			c.rollbackPrepared(context.WithoutCancel(ctx), report, 0, i)
+			return fmt.Errorf("cannot prepare transaction on %s: %w", c.resources[i].name, err)
+		}
+		report.Resources[i].PreparedID = id
+	}
+
+	// Все транзакции подготовлены и решение о коммите принято: его нужно
+	// применить и после таймаута или отмены ctx, иначе подготовленные
+	// транзакции будут держать блокировки до ручного разбора
+	ctx = context.WithoutCancel(ctx)
+	for i, r := range c.resources {
+		id := report.Resources[i].PreparedID
+		if _, err := r.db.ExecContext(ctx, fmt.Sprintf("COMMIT PREPARED '%s'", id)); err != nil {
+			report.Resources[i].Err = err
+
+			c.rollbackPrepared(ctx, report, i, len(txs))
+			c.compensate(parent, co, report, i)
+			return c.commitError(report, i, err)
+		}
+		report.Resources[i].Status = ResourceCommitted
+	}
+	return nil
+}
+
+// prepare выполняет PREPARE TRANSACTION. После этого сессия уже не находится
+// в транзакции: подготовленная транзакция существует отдельно от соединения,
+// а COMMIT лишь возвращает соединение в пул и на нее не влияет
+func prepare(ctx context.Context, tx *SQLTx, id string) error {
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PREPARE TRANSACTION '%s'", id)); err != nil {
+		return err
+	}
+	_ = tx.Tx.Commit()
+	return nil
+}
+
+// newPreparedID генерирует уникальный префикс идентификаторов подготовленных транзакций
+func newPreparedID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("cannot generate prepared transaction id: %w", err)
+	}
+	return "gotrx_" + hex.EncodeToString(b), nil
+}
+
+// rollback откатывает транзакции, начиная с индекса from
+func (c *Coordinator) rollback(ctx context.Context, txs []*transaction, report *CommitReport, from int) {
+	for i := from; i < len(txs); i++ {
+		report.Resources[i].Status = ResourceRolledBack
+		report.Resources[i].Err = rollback(ctx, txs[i].tx)
+	}
+}
+
+// rollbackPrepared откатывает подготовленные транзакции с индексами [from, to)
+func (c *Coordinator) rollbackPrepared(ctx context.Context, report *CommitReport, from, to int) {
+	for i := from; i < to; i++ {
+		res := &report.Resources[i]
+		_, err := c.resources[i].db.ExecContext(ctx, fmt.Sprintf("ROLLBACK PREPARED '%s'", res.PreparedID))
+		if err != nil {
+			res.Status = ResourceInDoubt
+			res.Err = errors.Join(res.Err, err)
+			continue
+		}
+		res.Status = ResourceRolledBack
+	}
+}
+
+// compensate выполняет компенсации ресурсов, закоммиченных до ресурса failed,
+// в обратном порядке
+func (c *Coordinator) compensate(ctx context.Context, co *coordination, report *CommitReport, failed int) {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+
+	for i := failed - 1; i >= 0; i-- {
+		fns := co.compensations[c.resources[i].trm]
+		if len(fns) == 0 {
+			continue
+		}
+
+		var errs []error
+		for j := len(fns) - 1; j >= 0; j-- {
+			if err := fns[j](ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		res := &report.Resources[i]
+		if err := errors.Join(errs...); err != nil {
+			res.Status = ResourceCompensationFailed
+			res.Err = err
+			continue
+		}
+		res.Status = ResourceCompensated
+	}
+}
+
+// commitError оборачивает ошибку коммита ресурса i
+func (c *Coordinator) commitError(report *CommitReport, i int, err error) error {
+	err = fmt.Errorf("cannot commit transaction on %s: %w", c.resources[i].name, err)
+	if report.partial() {
+		return fmt.Errorf("%w: %w", ErrPartialCommit, err)
+	}
+	return err
+}
+
+// runHooks выполняет хуки транзакций по итоговому состоянию ресурсов:
+// AfterCommit - если изменения ресурса остались в БД, иначе AfterRollback
+func (c *Coordinator) runHooks(ctx context.Context, txs []*transaction, report *CommitReport) {
+	for i, t := range txs {
+		s := c.resources[i].trm.defaults
+		switch report.Resources[i].Status {
+		case ResourceCommitted, ResourceCompensationFailed:
+			runHooks(ctx, s, t.hooks.committed())
+		default:
+			runHooks(ctx, s, t.hooks.rolledBack())
+		}
+	}
+}
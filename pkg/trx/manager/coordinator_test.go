@@ -0,0 +1,310 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCoordinatedDBs создает два менеджера на моках БД orders и billing
+func newCoordinatedDBs(t *testing.T) (orders, billing *TransactionManager, ordersMock, billingMock sqlmock.Sqlmock) {
+	ordersDB, ordersMock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { ordersDB.Close() })
+	billingDB, billingMock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { billingDB.Close() })
+
+	return NewTransactionManager(ordersDB), NewTransactionManager(billingDB), ordersMock, billingMock
+}
+
+func TestCoordinator_CommitsInOrder(t *testing.T) {
+	orders, billing, ordersMock, billingMock := newCoordinatedDBs(t)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(1, 1))
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectExec("INSERT INTO invoices").WillReturnResult(sqlmock.NewResult(1, 1))
+	billingMock.ExpectCommit()
+
+	c := NewCoordinator().Register("orders", orders).Register("billing", billing)
+	committed := false
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error {
+		require.NoError(t, AfterCommit(ctx, func(ctx context.Context) { committed = true }))
+
+		_, err := NewQuerier(orders).ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)")
+		require.NoError(t, err)
+		_, err = NewQuerier(billing).ExecContext(ctx, "INSERT INTO invoices (order_id) VALUES (1)")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders", "billing"}, report.Committed())
+	assert.Equal(t, "orders: committed, billing: committed", report.String())
+	assert.True(t, committed)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCoordinator_RollsBackAllOnError(t *testing.T) {
+	orders, billing, ordersMock, billingMock := newCoordinatedDBs(t)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectRollback()
+	billingMock.ExpectBegin()
+	billingMock.ExpectRollback()
+
+	c := NewCoordinator().Register("orders", orders).Register("billing", billing)
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error {
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Empty(t, report.Committed())
+	assert.Equal(t, "orders: rolled back, billing: rolled back", report.String())
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCoordinator_CompensatesOnLaterCommitFailure(t *testing.T) {
+	orders, billing, ordersMock, billingMock := newCoordinatedDBs(t)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectCommit().WillReturnError(errors.New("connection reset"))
+	// Компенсация выполняется в отдельной транзакции orders
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec("DELETE FROM orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	ordersMock.ExpectCommit()
+
+	c := NewCoordinator().Register("orders", orders).Register("billing", billing)
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error {
+		return Compensate(ctx, orders, func(ctx context.Context) error {
+			return DoVoid(ctx, orders, func(ctx context.Context) error {
+				_, err := NewQuerier(orders).ExecContext(ctx, "DELETE FROM orders WHERE id = 1")
+				return err
+			})
+		})
+	})
+
+	assert.EqualError(t, err, "coordinated commit left resources partially committed: cannot commit transaction on billing: connection reset")
+	assert.ErrorIs(t, err, ErrPartialCommit)
+	assert.Equal(t, ResourceCompensated, report.Resources[0].Status)
+	assert.Equal(t, ResourceCommitFailed, report.Resources[1].Status)
+	assert.Empty(t, report.Committed())
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCoordinator_CompensationFailure(t *testing.T) {
+	orders, billing, ordersMock, billingMock := newCoordinatedDBs(t)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectCommit().WillReturnError(errors.New("connection reset"))
+
+	c := NewCoordinator().Register("orders", orders).Register("billing", billing)
+	compensationErr := errors.New("compensation failed")
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error {
+		return Compensate(ctx, orders, func(ctx context.Context) error { return compensationErr })
+	})
+
+	assert.ErrorIs(t, err, ErrPartialCommit)
+	assert.Equal(t, ResourceCompensationFailed, report.Resources[0].Status)
+	assert.ErrorIs(t, report.Resources[0].Err, compensationErr)
+	assert.Equal(t, []string{"orders"}, report.Committed())
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCoordinator_TwoPhaseCommit(t *testing.T) {
+	orders, billing, ordersMock, billingMock := newCoordinatedDBs(t)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec(`PREPARE TRANSACTION 'gotrx_[0-9a-f]{16}_0'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectExec(`PREPARE TRANSACTION 'gotrx_[0-9a-f]{16}_1'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectCommit()
+	ordersMock.ExpectExec(`COMMIT PREPARED 'gotrx_[0-9a-f]{16}_0'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectExec(`COMMIT PREPARED 'gotrx_[0-9a-f]{16}_1'`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	c := NewCoordinator(WithTwoPhaseCommit()).Register("orders", orders).Register("billing", billing)
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error { return nil })
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders", "billing"}, report.Committed())
+	assert.NotEmpty(t, report.Resources[0].PreparedID)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCoordinator_TwoPhasePrepareFailure(t *testing.T) {
+	orders, billing, ordersMock, billingMock := newCoordinatedDBs(t)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec("PREPARE TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectExec("PREPARE TRANSACTION").WillReturnError(errors.New("max_prepared_transactions is zero"))
+	billingMock.ExpectRollback()
+	ordersMock.ExpectExec("ROLLBACK PREPARED").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	c := NewCoordinator(WithTwoPhaseCommit()).Register("orders", orders).Register("billing", billing)
+	compensated := false
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error {
+		return Compensate(ctx, orders, func(ctx context.Context) error {
+			compensated = true
+			return nil
+		})
+	})
+
+	assert.EqualError(t, err, "cannot prepare transaction on billing: max_prepared_transactions is zero")
+	assert.NotErrorIs(t, err, ErrPartialCommit)
+	assert.False(t, compensated, "nothing was committed, compensations must not run")
+	assert.Equal(t, "orders: rolled back, billing: rolled back (max_prepared_transactions is zero)", report.String())
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCoordinator_TwoPhaseInDoubt(t *testing.T) {
+	orders, billing, ordersMock, billingMock := newCoordinatedDBs(t)
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec("PREPARE TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectExec("PREPARE TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectCommit()
+	ordersMock.ExpectExec("COMMIT PREPARED").WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectExec("COMMIT PREPARED").WillReturnError(errors.New("connection reset"))
+	billingMock.ExpectExec("ROLLBACK PREPARED").WillReturnError(errors.New("connection reset"))
+
+	c := NewCoordinator(WithTwoPhaseCommit()).Register("orders", orders).Register("billing", billing)
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error { return nil })
+
+	assert.ErrorIs(t, err, ErrPartialCommit)
+	assert.Equal(t, ResourceCommitted, report.Resources[0].Status)
+	assert.Equal(t, ResourceInDoubt, report.Resources[1].Status)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCoordinator_TwoPhaseTimeoutDuringCommit(t *testing.T) {
+	ordersDB, ordersMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer ordersDB.Close()
+	billingDB, billingMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer billingDB.Close()
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec("PREPARE TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectExec("PREPARE TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectCommit()
+	// Таймаут orders истекает во время COMMIT PREPARED
+	ordersMock.ExpectExec("COMMIT PREPARED").WillDelayFor(100 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+	billingMock.ExpectExec("COMMIT PREPARED").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	orders := NewTransactionManager(ordersDB, WithTimeout(50*time.Millisecond))
+	billing := NewTransactionManager(billingDB)
+	c := NewCoordinator(WithTwoPhaseCommit()).Register("orders", orders).Register("billing", billing)
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error { return nil })
+
+	// Решение о коммите принято после подготовки всех транзакций и применяется до конца
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"orders", "billing"}, report.Committed())
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestCompensate_OutsideCoordinator(t *testing.T) {
+	noop := func(ctx context.Context) error { return nil }
+
+	assert.ErrorIs(t, Compensate(context.Background(), NewTransactionManager(nil), noop), ErrNoCoordinator)
+}
+
+func TestCoordinator_RejectsReadOnlyManager(t *testing.T) {
+	assert.Panics(t, func() {
+		NewCoordinator().Register("reports", NewTransactionManager(nil, WithReadOnly()))
+	})
+}
+
+func TestCoordinator_AppliesManagerSettings(t *testing.T) {
+	ordersDB, ordersMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer ordersDB.Close()
+	billingDB, billingMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer billingDB.Close()
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectRollback()
+	billingMock.ExpectBegin()
+	billingMock.ExpectRollback()
+
+	orders := NewTransactionManager(ordersDB, WithTimeout(10*time.Millisecond))
+	billing := NewTransactionManager(billingDB)
+	c := NewCoordinator().Register("orders", orders).Register("billing", billing)
+
+	var leaked *SQLTx
+	_, err = c.Do(context.Background(), func(ctx context.Context) error {
+		leaked, _ = TxFromContext(ctx, billing)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	// Таймаут менеджера orders ограничивает весь Do
+	assert.ErrorIs(t, err, ErrTransactionTimeout)
+	assert.Equal(t, Metrics{Rollbacks: 1, RollbackDuration: orders.Metrics().RollbackDuration, TimedOut: 1}, orders.Metrics())
+	assert.Equal(t, int64(1), billing.Metrics().Rollbacks)
+
+	_, err = leaked.ExecContext(context.Background(), "INSERT INTO invoices (order_id) VALUES (1)")
+	assert.ErrorIs(t, err, ErrTxCompleted)
+
+	// database/sql откатывает транзакции отмененного контекста асинхронно
+	assert.Eventually(t, func() bool {
+		return ordersMock.ExpectationsWereMet() == nil && billingMock.ExpectationsWereMet() == nil
+	}, time.Second, time.Millisecond)
+}
+
+func TestCoordinator_PanicAsError(t *testing.T) {
+	ordersDB, ordersMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer ordersDB.Close()
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectRollback()
+
+	orders := NewTransactionManager(ordersDB, WithPanicError())
+	c := NewCoordinator().Register("orders", orders)
+
+	report, err := c.Do(context.Background(), func(ctx context.Context) error {
+		panic("boom")
+	})
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Equal(t, ResourceRolledBack, report.Resources[0].Status)
+	assert.Equal(t, int64(1), orders.Metrics().Rollbacks)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+}
@@ -0,0 +1,29 @@
+package gotrxmanager
+
+import "context"
+
+// Do - типизированный вариант Manager.Do
+// Выполняет f в транзакции trm с той же семантикой коммита/отката
+// и возвращает результат f без приведения типов
+func Do[R any, T Tx](ctx context.Context, trm *Manager[T], f func(ctx context.Context) (R, error), opts ...Option) (R, error) {
+	var res R
+	_, err := trm.Do(ctx, func(ctx context.Context) (any, error) {
+		var err error
+		res, err = f(ctx)
+		return nil, err
+	}, opts...)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+
+	return res, nil
+}
+
+// DoVoid - вариант Do для функций без результата
+func DoVoid[T Tx](ctx context.Context, trm *Manager[T], f func(ctx context.Context) error, opts ...Option) error {
+	_, err := trm.Do(ctx, func(ctx context.Context) (any, error) {
+		return nil, f(ctx)
+	}, opts...)
+	return err
+}
@@ -0,0 +1,77 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo_Typed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+
+	type order struct{ ID int }
+	result, err := Do(context.Background(), trm, func(ctx context.Context) (*order, error) {
+		tx, err := TxFromContext(ctx, trm)
+		assert.NoError(t, err)
+		assert.NotNil(t, tx)
+		return &order{ID: 42}, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 42, result.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDo_TypedRollbackReturnsZero(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+
+	result, err := Do(context.Background(), trm, func(ctx context.Context) (int, error) {
+		return 42, errors.New("operation failed")
+	})
+
+	assert.EqualError(t, err, "operation failed")
+	assert.Zero(t, result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDoVoid(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		return nil
+	})
+	assert.NoError(t, err)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		return errors.New("operation failed")
+	})
+	assert.EqualError(t, err, "operation failed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,149 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+// ErrNoActiveTransaction - хук регистрируется вне транзакции
+var ErrNoActiveTransaction = errors.New("cannot register hook: no active transaction in context")
+
+// HookError - паника внутри хука AfterCommit/AfterRollback.
+// Не влияет на результат Do и передается в обработчик WithHookErrorHandler.
+type HookError struct {
+	Value any    // Значение, переданное в panic
+	Stack []byte // Стек горутины в момент паники
+}
+
+// Error возвращает текст ошибки со значением паники
+func (e *HookError) Error() string {
+	return fmt.Sprintf("panic in transaction hook: %v", e.Value)
+}
+
+// Unwrap возвращает значение паники, если это ошибка
+func (e *HookError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// AfterCommit регистрирует fn, которая выполнится после успешного коммита
+// транзакции из контекста. Хуки выполняются по порядку регистрации и получают
+// контекст вызова Do без транзакции.
+//
+// Хуки вложенного Do привязываются к коммиту внешней транзакции; если точка
+// сохранения вложенного Do откатывается, его хуки AfterCommit отбрасываются.
+// Если в контексте открыты транзакции нескольких менеджеров, хук привязывается
+// к транзакции самого внутреннего Do.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) error {
+	t, ok := ctx.Value(currentTrxKey).(*transaction)
+	if !ok {
+		return ErrNoActiveTransaction
+	}
+
+	t.hooks.mu.Lock()
+	defer t.hooks.mu.Unlock()
+	t.hooks.afterCommit = append(t.hooks.afterCommit, fn)
+	return nil
+}
+
+// AfterRollback регистрирует fn, которая выполнится после отката транзакции
+// из контекста (в том числе из-за ошибки коммита или паники).
+// Если внутри вложенного Do откатывается его точка сохранения, хуки
+// AfterRollback, зарегистрированные в нем, выполняются сразу после этого отката.
+func AfterRollback(ctx context.Context, fn func(ctx context.Context)) error {
+	t, ok := ctx.Value(currentTrxKey).(*transaction)
+	if !ok {
+		return ErrNoActiveTransaction
+	}
+
+	t.hooks.mu.Lock()
+	defer t.hooks.mu.Unlock()
+	t.hooks.afterRollback = append(t.hooks.afterRollback, fn)
+	return nil
+}
+
+// WithHookErrorHandler задает обработчик паник в хуках.
+// По умолчанию ошибки хуков пишутся в slog.Default().
+func WithHookErrorHandler(handler func(ctx context.Context, err error)) Option {
+	return func(s *settings) {
+		s.hookErrorHandler = handler
+	}
+}
+
+// defaultHookErrorHandler пишет ошибку хука в стандартный логгер
+func defaultHookErrorHandler(ctx context.Context, err error) {
+	slog.ErrorContext(ctx, "transaction hook failed", "error", err)
+}
+
+// hooks - хуки, зарегистрированные в транзакции
+type hooks struct {
+	mu            sync.Mutex
+	afterCommit   []func(ctx context.Context)
+	afterRollback []func(ctx context.Context)
+}
+
+// hooksMark - позиция в списках хуков на момент начала вложенного Do
+type hooksMark struct {
+	commit   int
+	rollback int
+}
+
+// mark запоминает текущее количество хуков
+func (h *hooks) mark() hooksMark {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return hooksMark{commit: len(h.afterCommit), rollback: len(h.afterRollback)}
+}
+
+// rollbackTo отбрасывает хуки, зарегистрированные после m, и возвращает
+// хуки отката из этого диапазона
+func (h *hooks) rollbackTo(m hooksMark) []func(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rolledBack := slices.Clone(h.afterRollback[m.rollback:])
+	h.afterCommit = h.afterCommit[:m.commit]
+	h.afterRollback = h.afterRollback[:m.rollback]
+	return rolledBack
+}
+
+// committed возвращает хуки для выполнения после коммита
+func (h *hooks) committed() []func(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.afterCommit
+}
+
+// rolledBack возвращает хуки для выполнения после отката
+func (h *hooks) rolledBack() []func(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.afterRollback
+}
+
+// runHooks выполняет хуки по порядку. Паника в хуке не прерывает
+// остальные хуки и передается в обработчик ошибок хуков
+func runHooks(ctx context.Context, s settings, fns []func(ctx context.Context)) {
+	for _, fn := range fns {
+		if err := callHook(ctx, fn); err != nil {
+			s.hookErrorHandler(ctx, err)
+		}
+	}
+}
+
+// callHook вызывает хук, перехватывая панику
+func callHook(ctx context.Context, fn func(ctx context.Context)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &HookError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	fn(ctx)
+	return nil
+}
@@ -0,0 +1,166 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAfterCommit_RunsInOrderAfterCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	var events []string
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		require.NoError(t, AfterCommit(ctx, func(ctx context.Context) {
+			// Хук получает контекст без транзакции
+			_, err := TxFromContext(ctx, trm)
+			assert.Error(t, err)
+			events = append(events, "first")
+		}))
+		require.NoError(t, AfterCommit(ctx, func(ctx context.Context) { events = append(events, "second") }))
+		require.NoError(t, AfterRollback(ctx, func(ctx context.Context) { events = append(events, "rollback") }))
+
+		// До коммита хуки не выполняются
+		assert.Empty(t, events)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAfterRollback_RunsOnError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+	var events []string
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		AfterCommit(ctx, func(ctx context.Context) { events = append(events, "commit") })
+		AfterRollback(ctx, func(ctx context.Context) { events = append(events, "rollback") })
+		return errors.New("operation failed")
+	})
+
+	assert.EqualError(t, err, "operation failed")
+	assert.Equal(t, []string{"rollback"}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAfterRollback_RunsOnCommitError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(errors.New("commit error"))
+
+	trm := NewTransactionManager(db)
+	var events []string
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		AfterCommit(ctx, func(ctx context.Context) { events = append(events, "commit") })
+		AfterRollback(ctx, func(ctx context.Context) { events = append(events, "rollback") })
+		return nil
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, []string{"rollback"}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_NestedSavepoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "RELEASE SAVEPOINT sp_1")
+	expectExec(mock, "SAVEPOINT sp_2")
+	expectExec(mock, "ROLLBACK TO SAVEPOINT sp_2")
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	var events []string
+	record := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) { events = append(events, name) }
+	}
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		AfterCommit(ctx, record("outer commit"))
+
+		// Успешный вложенный вызов - хук привязывается к внешнему коммиту
+		DoVoid(ctx, trm, func(ctx context.Context) error {
+			AfterCommit(ctx, record("inner commit"))
+			return nil
+		})
+		assert.Empty(t, events)
+
+		// Откаченный вложенный вызов - хук коммита отбрасывается, хук отката выполняется сразу
+		DoVoid(ctx, trm, func(ctx context.Context) error {
+			AfterCommit(ctx, record("discarded commit"))
+			AfterRollback(ctx, record("savepoint rollback"))
+			return errors.New("inner failed")
+		})
+		assert.Equal(t, []string{"savepoint rollback"}, events)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"savepoint rollback", "outer commit", "inner commit"}, events)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_PanicReportedSeparately(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	var hookErrs []error
+	trm := NewTransactionManager(db, WithHookErrorHandler(func(ctx context.Context, err error) {
+		hookErrs = append(hookErrs, err)
+	}))
+	ran := false
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		AfterCommit(ctx, func(ctx context.Context) { panic("publish failed") })
+		AfterCommit(ctx, func(ctx context.Context) { ran = true })
+		return nil
+	})
+
+	// Транзакция закоммичена, ошибка хука не влияет на результат Do
+	assert.NoError(t, err)
+	assert.True(t, ran, "hooks after the failed one should still run")
+	require.Len(t, hookErrs, 1)
+	var hookErr *HookError
+	require.ErrorAs(t, hookErrs[0], &hookErr)
+	assert.Equal(t, "publish failed", hookErr.Value)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_OutsideTransaction(t *testing.T) {
+	noop := func(ctx context.Context) {}
+
+	assert.ErrorIs(t, AfterCommit(context.Background(), noop), ErrNoActiveTransaction)
+	assert.ErrorIs(t, AfterRollback(context.Background(), noop), ErrNoActiveTransaction)
+}
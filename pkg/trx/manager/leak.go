@@ -0,0 +1,106 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// ErrTxCompleted - транзакция используется после завершения Do, который ее открыл.
+// Обычно это горутина, запущенная внутри f и пережившая Do.
+var ErrTxCompleted = errors.New("transaction used after Do completed")
+
+// TxCompletedError возвращается запросами SQLTx после завершения Do,
+// открывшего транзакцию. Сопоставляется с ErrTxCompleted и sql.ErrTxDone.
+//
+// QueryRowContext и StmtContext возвращают *sql.Row и *sql.Stmt, которые
+// не могут содержать эту ошибку: после завершения Do их запрос не выполняется,
+// а ошибкой становится context.Canceled. Найти такие обращения помогает
+// WithLeakDetection.
+type TxCompletedError struct {
+	Stack string // Стек вызова Do, открывшего транзакцию
+}
+
+// Error возвращает текст ошибки со стеком открытия транзакции
+func (e *TxCompletedError) Error() string {
+	return fmt.Sprintf("%s; transaction was started at:\n%s", ErrTxCompleted, e.Stack)
+}
+
+// Is сопоставляет ошибку с ErrTxCompleted и sql.ErrTxDone,
+// который database/sql возвращает для завершенных транзакций
+func (e *TxCompletedError) Is(target error) bool {
+	return target == ErrTxCompleted || target == sql.ErrTxDone
+}
+
+// WithLeakDetection включает отладочный режим: каждое использование транзакции
+// после завершения Do пишется в лог (WithLogger) со стеком открытия транзакции
+// и стеком обращения к ней. Так находятся утечки, даже если вызывающий код
+// игнорирует ошибку или использует QueryRowContext.
+func WithLeakDetection() Option {
+	return func(s *settings) {
+		s.leakDetection = true
+	}
+}
+
+// completable реализуется транзакциями, которые отслеживают использование
+// после завершения Do
+type completable interface {
+	// track запоминает стек открытия транзакции и обработчик утечек (nil - не сообщать)
+	track(started []uintptr, report func(err error))
+	// complete помечает транзакцию завершенной
+	complete()
+}
+
+// trackCompletion начинает отслеживать использование транзакции после завершения Do.
+// Возвращает функцию, которую нужно вызвать после завершения транзакции
+func trackCompletion(ctx context.Context, s settings, tx Tx) func() {
+	c, ok := tx.(completable)
+	if !ok {
+		return func() {}
+	}
+
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+
+	var report func(err error)
+	if s.leakDetection {
+		logger := s.logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+		report = func(err error) {
+			logger.ErrorContext(context.WithoutCancel(ctx), "transaction used after completion",
+				"error", err, "stack", string(debug.Stack()))
+		}
+	}
+
+	c.track(pcs[:n], report)
+	return c.complete
+}
+
+// formatStack форматирует стек, пропуская кадры пакета до первого вызова извне
+// (тесты пакета считаются внешним кодом)
+func formatStack(pcs []uintptr) string {
+	var b strings.Builder
+	outside := false
+
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if !outside && (filepath.Dir(frame.File) != packageDir || strings.HasSuffix(frame.File, "_test.go")) {
+			outside = true
+		}
+		if outside {
+			fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return b.String()
+		}
+	}
+}
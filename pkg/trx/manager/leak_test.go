@@ -0,0 +1,177 @@
+package gotrxmanager
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leakTx возвращает транзакцию, пережившую Do
+func leakTx(t *testing.T, trm *TransactionManager) *SQLTx {
+	var leaked *SQLTx
+	err := DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		tx, err := TxFromContext(ctx, trm)
+		leaked = tx
+		return err
+	})
+	require.NoError(t, err)
+	return leaked
+}
+
+func TestSQLTx_UseAfterCompletion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	tx := leakTx(t, NewTransactionManager(db))
+
+	_, err = tx.ExecContext(context.Background(), "UPDATE accounts SET balance = 0")
+	assert.ErrorIs(t, err, ErrTxCompleted)
+	assert.ErrorIs(t, err, sql.ErrTxDone)
+
+	var completedErr *TxCompletedError
+	require.True(t, errors.As(err, &completedErr))
+	assert.Contains(t, completedErr.Stack, "leak_test.go:", "stack should point to the Do call site")
+	assert.NotContains(t, completedErr.Stack, "manager.go:")
+
+	_, err = tx.Query("SELECT 1")
+	assert.ErrorIs(t, err, ErrTxCompleted)
+
+	var n int
+	assert.Error(t, tx.QueryRow("SELECT 1").Scan(&n))
+
+	assert.Zero(t, tx.Statements())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLTx_UseAfterCompletionInSavepointManager(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT trx_1")
+	expectExec(mock, "RELEASE SAVEPOINT trx_1")
+
+	outer, err := db.Begin()
+	require.NoError(t, err)
+	tx := leakTx(t, NewSavepointTransactionManager(outer))
+
+	// Внешняя транзакция еще открыта, но запрос утекшей транзакции не выполняется
+	_, err = tx.ExecContext(context.Background(), "DELETE FROM accounts")
+	assert.ErrorIs(t, err, ErrTxCompleted)
+
+	var n int
+	assert.Error(t, tx.QueryRowContext(context.Background(), "SELECT 1").Scan(&n))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLTx_PrepareAfterCompletion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectPrepare("SELECT balance")
+	mock.ExpectBegin()
+	mock.ExpectPrepare("UPDATE accounts")
+	mock.ExpectCommit()
+
+	stmt, err := db.Prepare("SELECT balance FROM accounts WHERE id = $1")
+	require.NoError(t, err)
+	defer stmt.Close()
+
+	trm := NewTransactionManager(db)
+	var leaked *SQLTx
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		leaked, _ = TxFromContext(ctx, trm)
+		// Подготовка через Querier тоже учитывается в количестве запросов
+		_, err := NewQuerier(trm).PrepareContext(ctx, "UPDATE accounts SET balance = $1")
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), leaked.Statements())
+
+	_, err = leaked.Prepare("UPDATE accounts SET balance = $1")
+	assert.ErrorIs(t, err, ErrTxCompleted)
+
+	_, err = leaked.Stmt(stmt).Exec(1)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	assert.Equal(t, int64(1), leaked.Statements())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithLeakDetection_ReportsUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	var logs bytes.Buffer
+	trm := NewTransactionManager(db,
+		WithLeakDetection(),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+
+	var leaked *SQLTx
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		leaked, _ = TxFromContext(ctx, trm)
+		return assert.AnError
+	})
+	require.ErrorIs(t, err, assert.AnError)
+
+	// Ошибка QueryRow теряется, но утечка попадает в лог
+	var n int
+	_ = leaked.QueryRow("SELECT 1").Scan(&n)
+
+	var entry struct {
+		Msg   string `json:"msg"`
+		Error string `json:"error"`
+		Stack string `json:"stack"`
+	}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "transaction used after completion", entry.Msg)
+	assert.Contains(t, entry.Error, "transaction was started at")
+	assert.Contains(t, entry.Stack, "TestWithLeakDetection_ReportsUse")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSQLTx_UseBeforeCompletionNotReported(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "UPDATE accounts")
+	mock.ExpectCommit()
+
+	var logs bytes.Buffer
+	trm := NewTransactionManager(db,
+		WithLeakDetection(),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+	)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		tx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "UPDATE accounts SET balance = 0")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, logs.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,129 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// ErrLocksUnsupported возвращается LockXact/TryLockXact, если транзакция
+// из контекста открыта не через TransactionManager
+var ErrLocksUnsupported = errors.New("advisory locks require a transaction of TransactionManager")
+
+// LockKey - ключ advisory-блокировки PostgreSQL.
+// Строковые ключи хэшируются в int64 (FNV-1a), поэтому одна и та же строка
+// во всех процессах дает одну и ту же блокировку.
+type LockKey interface {
+	int64 | string
+}
+
+// LockXact захватывает advisory-блокировку key в транзакции из контекста,
+// ожидая ее освобождения другими транзакциями (pg_advisory_xact_lock).
+// Блокировка освобождается автоматически при коммите или откате транзакции.
+// Если в контексте открыты транзакции нескольких менеджеров, используется
+// транзакция самого внутреннего Do. Вне транзакции возвращает ErrNoTransaction.
+func LockXact[K LockKey](ctx context.Context, key K) error {
+	tx, err := lockTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", lockID(key)); err != nil {
+		return fmt.Errorf("cannot acquire advisory lock %v: %w", key, err)
+	}
+	return nil
+}
+
+// TryLockXact захватывает advisory-блокировку key в транзакции из контекста
+// без ожидания (pg_try_advisory_xact_lock). Возвращает false, если блокировка
+// занята другой транзакцией. Освобождается так же, как блокировка LockXact.
+func TryLockXact[K LockKey](ctx context.Context, key K) (bool, error) {
+	tx, err := lockTx(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", lockID(key)).Scan(&locked); err != nil {
+		return false, fmt.Errorf("cannot acquire advisory lock %v: %w", key, err)
+	}
+	return locked, nil
+}
+
+// WithLock выполняет fn под сессионной advisory-блокировкой key (pg_advisory_lock),
+// ожидая ее освобождения другими сессиями. Блокировка захватывается на отдельном
+// соединении из db и снимается после завершения fn, даже если fn паникует.
+// Подходит для долгих задач, которые нельзя держать в одной транзакции.
+func WithLock[K LockKey](ctx context.Context, db *sql.DB, key K, fn func(ctx context.Context) error) error {
+	_, err := withSessionLock(ctx, db, key, "SELECT true FROM pg_advisory_lock($1)", fn)
+	return err
+}
+
+// TryWithLock выполняет fn под сессионной advisory-блокировкой key без ожидания
+// (pg_try_advisory_lock). Если блокировка занята, fn не вызывается и возвращается false.
+func TryWithLock[K LockKey](ctx context.Context, db *sql.DB, key K, fn func(ctx context.Context) error) (bool, error) {
+	return withSessionLock(ctx, db, key, "SELECT pg_try_advisory_lock($1)", fn)
+}
+
+// withSessionLock захватывает блокировку запросом lockQuery, возвращающим bool,
+// и выполняет fn, если блокировка получена
+func withSessionLock[K LockKey](ctx context.Context, db *sql.DB, key K, lockQuery string, fn func(ctx context.Context) error) (locked bool, err error) {
+	id := lockID(key)
+
+	// Сессионная блокировка принадлежит соединению, поэтому захват
+	// и освобождение должны выполняться на одном и том же *sql.Conn
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cannot acquire advisory lock %v: %w", key, err)
+	}
+	defer conn.Close()
+
+	if err := conn.QueryRowContext(ctx, lockQuery, id).Scan(&locked); err != nil {
+		return false, fmt.Errorf("cannot acquire advisory lock %v: %w", key, err)
+	}
+	if !locked {
+		return false, nil
+	}
+
+	defer func() {
+		// Блокировка снимается и после отмены ctx
+		_, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", id)
+		if unlockErr == nil {
+			return
+		}
+		// Соединение с неснятой блокировкой нельзя возвращать в пул:
+		// ErrBadConn заставляет database/sql закрыть его, что снимает блокировку
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		err = errors.Join(err, fmt.Errorf("cannot release advisory lock %v: %w", key, unlockErr))
+	}()
+
+	return true, fn(ctx)
+}
+
+// lockTx возвращает транзакцию самого внутреннего Do
+func lockTx(ctx context.Context) (*SQLTx, error) {
+	t, ok := ctx.Value(currentTrxKey).(*transaction)
+	if !ok {
+		return nil, ErrNoTransaction
+	}
+	tx, ok := t.tx.(*SQLTx)
+	if !ok {
+		return nil, ErrLocksUnsupported
+	}
+	return tx, nil
+}
+
+// lockID возвращает числовой идентификатор блокировки
+func lockID[K LockKey](key K) int64 {
+	switch k := any(key).(type) {
+	case string:
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(k))
+		return int64(h.Sum64())
+	default:
+		return any(key).(int64)
+	}
+}
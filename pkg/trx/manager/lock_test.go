@@ -0,0 +1,146 @@
+package gotrxmanager
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockXact(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).
+		WithArgs(int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock($1)")).
+		WithArgs(lockID("billing")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		if err := LockXact(ctx, int64(42)); err != nil {
+			return err
+		}
+		return LockXact(ctx, "billing")
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTryLockXact(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_xact_lock($1)")).
+		WithArgs(lockID("billing")).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		locked, err := TryLockXact(ctx, "billing")
+		require.NoError(t, err)
+		assert.False(t, locked)
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockXact_NoTransaction(t *testing.T) {
+	assert.ErrorIs(t, LockXact(context.Background(), "billing"), ErrNoTransaction)
+
+	_, err := TryLockXact(context.Background(), int64(1))
+	assert.ErrorIs(t, err, ErrNoTransaction)
+
+	var txs []*fakeTx
+	trm := NewManager(fakeBeginner(&txs))
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		return LockXact(ctx, "billing")
+	})
+	assert.ErrorIs(t, err, ErrLocksUnsupported)
+}
+
+func TestWithLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT true FROM pg_advisory_lock($1)")).
+		WithArgs(lockID("migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).
+		WithArgs(lockID("migrations")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	called := false
+	err = WithLock(context.Background(), db, "migrations", func(ctx context.Context) error {
+		called = true
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.True(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTryWithLock_Busy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+
+	locked, err := TryWithLock(context.Background(), db, int64(7), func(ctx context.Context) error {
+		t.Error("fn must not be called when the lock is busy")
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.False(t, locked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTryWithLock_UnlockError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock($1)")).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).
+		WithArgs(int64(7)).
+		WillReturnError(assert.AnError)
+	// Соединение с неснятой блокировкой закрывается, а не возвращается в пул
+	mock.ExpectClose()
+
+	locked, err := TryWithLock(context.Background(), db, int64(7), func(ctx context.Context) error { return nil })
+
+	assert.True(t, locked)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Contains(t, err.Error(), "cannot release advisory lock 7")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLockID(t *testing.T) {
+	assert.Equal(t, int64(42), lockID(int64(42)))
+	assert.Equal(t, lockID("billing"), lockID("billing"))
+	assert.NotEqual(t, lockID("billing"), lockID("reports"))
+}
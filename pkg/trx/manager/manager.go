@@ -3,67 +3,229 @@ package gotrxmanager
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+
+	"go-utils/pkg/clock"
 )
 
-// trxManagerKey - тип для ключа контекста, используемого для хранения транзакции
-type trxManagerKey string
+// trxManagerKey - тип для ключа контекста, используемого для хранения транзакции.
+// Каждый менеджер создает собственный ключ, поэтому транзакции менеджеров
+// разных БД хранятся в одном контексте независимо друг от друга
+type trxManagerKey struct {
+	name string
+}
 
-// trxKey - конкретный ключ для доступа к транзакции в контексте
-const trxKey trxManagerKey = "trxKey"
+// currentTrxKey - ключ транзакции самого внутреннего Do,
+// к которой привязываются хуки AfterCommit/AfterRollback
+var currentTrxKey = &trxManagerKey{name: "currentTrx"}
 
 // NewTransactionManager - конструктор для создания нового менеджера транзакций
-func NewTransactionManager(db *sql.DB) *TransactionManager {
-	return &TransactionManager{
-		db: db,
+// Опции задают настройки транзакций по умолчанию для всех вызовов Do
+// Реплики для чтения задаются опцией WithReplicas
+func NewTransactionManager(db *sql.DB, opts ...Option) *TransactionManager {
+	s := newSettings(defaultSettings(), opts)
+	return NewManager[*SQLTx](&sqlBeginner{db: db, replicas: s.replicas}, opts...)
+}
+
+// NewSavepointTransactionManager - конструктор менеджера, работающего внутри
+// уже открытой транзакции tx: каждая транзакция Do открывается как точка
+// сохранения в tx, ее коммит освобождает точку сохранения, а откат - откатывает к ней.
+// Querier этого менеджера вне Do тоже выполняет запросы в tx.
+// Завершать tx должен вызывающий код. Настройки изоляции, режима чтения
+// и реплики не применяются; вызывать Do из нескольких горутин одновременно нельзя.
+// Предназначен для тестов, см. пакет trxtest.
+func NewSavepointTransactionManager(tx *sql.Tx, opts ...Option) *TransactionManager {
+	s := newSettings(defaultSettings(), opts)
+	return NewManager[*SQLTx](&sqlBeginner{outer: tx, dialect: s.dialect}, opts...)
+}
+
+// NewManager - конструктор менеджера транзакций для произвольного драйвера.
+// Транзакции открываются через beginner, остальное поведение совпадает
+// с TransactionManager
+func NewManager[T Tx](beginner Beginner[T], opts ...Option) *Manager[T] {
+	return &Manager[T]{
+		key:      &trxManagerKey{name: "trxKey"},
+		beginner: beginner,
+		defaults: newSettings(defaultSettings(), opts),
 	}
 }
 
 // Do - выполняет функцию f в контексте транзакции
 // Автоматически обрабатывает начало/коммит/откат транзакции
-func (trm *TransactionManager) Do(ctx context.Context, f func(ctx context.Context) (any, error)) (any, error) {
+// Опции переопределяют настройки менеджера для этого вызова
+//
+// Поведение при уже открытой транзакции задается WithPropagation.
+// По умолчанию (PropagationNested) вложенный Do не открывает новую транзакцию:
+// f выполняется внутри точки сохранения (SAVEPOINT), и ошибка f откатывает
+// только ее работу. Уровень изоляции и режим чтения во вложенном вызове не меняются.
+func (trm *Manager[T]) Do(ctx context.Context, f func(ctx context.Context) (any, error), opts ...Option) (any, error) {
+	s := newSettings(trm.defaults, opts)
+	if s.slowThreshold > 0 {
+		s.caller = callerOutsidePackage()
+	}
+	t, inTx := trm.transactionFromContext(ctx)
+
+	switch s.propagation {
+	case PropagationRequired:
+		if inTx {
+			return trm.doJoined(ctx, t, f)
+		}
+	case PropagationRequiresNew:
+		// Внешняя транзакция (если есть) приостанавливается до завершения новой
+	case PropagationNested:
+		if inTx {
+			return trm.doNested(ctx, t, s, f)
+		}
+	case PropagationSupports:
+		if inTx {
+			return trm.doJoined(ctx, t, f)
+		}
+		return f(ctx)
+	case PropagationMandatory:
+		if !inTx {
+			return nil, ErrNoTransaction
+		}
+		return trm.doJoined(ctx, t, f)
+	case PropagationNever:
+		if inTx {
+			return nil, ErrTransactionExists
+		}
+		return f(ctx)
+	default:
+		return nil, fmt.Errorf("unknown propagation: %d", s.propagation)
+	}
+
+	return trm.doNewWithRetry(ctx, s, f)
+}
+
+// doNew - открывает новую транзакцию, выполняет в ней f и завершает ее
+func (trm *Manager[T]) doNew(ctx context.Context, s settings, f func(ctx context.Context) (any, error)) (res any, err error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, s.timeout, ErrTransactionTimeout)
+		defer cancel()
+	}
+
 	// Начинаем новую транзакцию
-	trx, err := trm.db.BeginTx(ctx, nil)
+	start := clock.OrDefault(s.clock).Now()
+	trx, err := trm.beginner.Begin(ctx, s.txOptions)
 	if err != nil {
-		return nil, err
+		return nil, timeoutError(ctx, err)
 	}
 
+	// Учитываем транзакцию в метриках при любом исходе, в том числе при панике
+	committed := false
+	defer func() {
+		if errors.Is(err, ErrTransactionTimeout) {
+			trm.metrics.timedOut.Add(1)
+		}
+		trm.finish(ctx, s, start, trx, committed)
+	}()
+
+	// Запросы в транзакции после завершения Do возвращают TxCompletedError
+	defer trackCompletion(ctx, s, trx)()
+
 	// Добавляем транзакцию в контекст
-	ctx = context.WithValue(ctx, trxKey, trx)
+	// Хуки получат исходный контекст, в котором транзакции уже нет
+	parent := ctx
+	t := &transaction{tx: trx}
+	ctx = trm.withTransaction(ctx, t)
 
 	// Выполняем пользовательскую функцию в контексте транзакции
-	res, err := f(ctx)
+	res, p, err := callRecovered(ctx, f)
+	if p != nil {
+		// При панике откатываем транзакцию, чтобы соединение не зависло в пуле
+		rbErr := rollback(ctx, trx)
+		runHooks(parent, s, t.hooks.rolledBack())
+		if !s.panicAsError {
+			panic(p.Value)
+		}
+		if rbErr != nil {
+			return nil, fmt.Errorf("cannot rollback transaction with err: %s prev error: %w", rbErr, p)
+		}
+		return nil, p
+	}
+	if err == nil && t.rollbackOnly {
+		// Присоединившийся вызов завершился ошибкой - коммитить нельзя
+		err = ErrRollbackOnly
+	}
+	if err == nil && ctx.Err() != nil {
+		// Контекст отменен во время работы f - результат коммитить нельзя
+		err = ctx.Err()
+	}
 	if err != nil {
+		err = timeoutError(ctx, err)
+
 		// При ошибке пытаемся откатить транзакцию
-		if rbErr := trx.Rollback(); rbErr != nil {
+		if rbErr := rollback(ctx, trx); rbErr != nil {
 			// Если откат не удался, объединяем ошибки
-			err = fmt.Errorf("cannot rollback transaction with err: %s prev error: %s", rbErr, err)
+			err = fmt.Errorf("cannot rollback transaction with err: %s prev error: %w", rbErr, err)
 		}
+		runHooks(parent, s, t.hooks.rolledBack())
 		return nil, err
 	}
 
 	// Если все успешно, коммитим транзакцию
-	if err := trx.Commit(); err != nil {
-		return nil, fmt.Errorf("cannot commit transaction with error: %s", err)
+	if err := trx.Commit(ctx); err != nil {
+		runHooks(parent, s, t.hooks.rolledBack())
+		return nil, &commitError{err: err}
 	}
+	committed = true
 
+	runHooks(parent, s, t.hooks.committed())
 	return res, nil
 }
 
-// TxFromContext - извлекает транзакцию из контекста
+// timeoutError помечает ошибку транзакции, отмененной по WithTimeout
+func timeoutError(ctx context.Context, err error) error {
+	if context.Cause(ctx) != ErrTransactionTimeout || errors.Is(err, ErrTransactionTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrTransactionTimeout, err)
+}
+
+// rollback - откатывает транзакцию
+// Если контекст отменен, database/sql уже откатил транзакцию сам,
+// поэтому sql.ErrTxDone в этом случае не считается ошибкой
+func rollback(ctx context.Context, trx Tx) error {
+	err := trx.Rollback(ctx)
+	if errors.Is(err, sql.ErrTxDone) && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// TxFromContext - извлекает из контекста транзакцию, открытую менеджером trm
 // Возвращает ошибку если транзакция не найдена или имеет неверный тип
-func TxFromContext(ctx context.Context) (*sql.Tx, error) {
-	// Получаем значение из контекста по ключу
-	t := ctx.Value(trxKey)
-	if t == nil {
-		return nil, fmt.Errorf("cannot find transaction")
+func TxFromContext[T Tx](ctx context.Context, trm *Manager[T]) (T, error) {
+	var zero T
+
+	// Получаем значение из контекста по ключу менеджера
+	v := ctx.Value(trm.key)
+	if v == nil {
+		return zero, fmt.Errorf("cannot find transaction")
 	}
 
-	// Пытаемся привести значение к типу *sql.Tx
-	tx, ok := t.(*sql.Tx)
+	// Пытаемся привести значение к типу транзакции менеджера
+	t, ok := v.(*transaction)
 	if !ok {
-		return nil, fmt.Errorf("received value is not a *sql.Tx")
+		return zero, fmt.Errorf("received value is not a transaction")
 	}
 
-	return tx, nil
+	return t.tx.(T), nil
+}
+
+// withTransaction - добавляет транзакцию менеджера в контекст
+// и делает ее текущей для хуков
+func (trm *Manager[T]) withTransaction(ctx context.Context, t *transaction) context.Context {
+	ctx = context.WithValue(ctx, trm.key, t)
+	return context.WithValue(ctx, currentTrxKey, t)
+}
+
+// transactionFromContext - возвращает транзакцию из контекста, если она
+// открыта этим менеджером
+func (trm *Manager[T]) transactionFromContext(ctx context.Context) (*transaction, bool) {
+	t, ok := ctx.Value(trm.key).(*transaction)
+	return t, ok
 }
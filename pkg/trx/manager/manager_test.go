@@ -7,6 +7,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTransactionManager_Do_Success(t *testing.T) {
@@ -27,7 +28,7 @@ func TestTransactionManager_Do_Success(t *testing.T) {
 	// Функция, которая будет выполняться в транзакции
 	testFunc := func(ctx context.Context) (any, error) {
 		// Получаем транзакцию из контекста
-		tx, err := TxFromContext(ctx)
+		tx, err := TxFromContext(ctx, trm)
 		assert.NoError(t, err)
 		assert.NotNil(t, tx)
 
@@ -103,7 +104,7 @@ func TestTransactionManager_Do_BeginTxError(t *testing.T) {
 
 func TestTxFromContext_NotFound(t *testing.T) {
 	// Пытаемся получить транзакцию из пустого контекста
-	tx, err := TxFromContext(context.Background())
+	tx, err := TxFromContext(context.Background(), NewTransactionManager(nil))
 
 	assert.Nil(t, tx)
 	assert.Error(t, err)
@@ -112,11 +113,124 @@ func TestTxFromContext_NotFound(t *testing.T) {
 
 func TestTxFromContext_InvalidType(t *testing.T) {
 	// Создаем контекст с неправильным типом значения, но с правильным ключом
-	ctx := context.WithValue(context.Background(), trxKey, "not a transaction")
+	trm := NewTransactionManager(nil)
+	ctx := context.WithValue(context.Background(), trm.key, "not a transaction")
 
-	tx, err := TxFromContext(ctx)
+	tx, err := TxFromContext(ctx, trm)
 
 	assert.Nil(t, tx)
 	assert.Error(t, err)
-	assert.EqualError(t, err, "received value is not a *sql.Tx")
+	assert.EqualError(t, err, "received value is not a transaction")
+}
+
+func TestTransactionManager_MultipleDatabases(t *testing.T) {
+	ordersDB, ordersMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer ordersDB.Close()
+	billingDB, billingMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer billingDB.Close()
+
+	ordersMock.ExpectBegin()
+	ordersMock.ExpectExec("INSERT INTO orders").WillReturnResult(sqlmock.NewResult(1, 1))
+	ordersMock.ExpectCommit()
+	billingMock.ExpectBegin()
+	billingMock.ExpectExec("INSERT INTO invoices").WillReturnResult(sqlmock.NewResult(1, 1))
+	billingMock.ExpectCommit()
+
+	orders := NewTransactionManager(ordersDB)
+	billing := NewTransactionManager(billingDB)
+	var events []string
+
+	err = DoVoid(context.Background(), orders, func(ctx context.Context) error {
+		AfterCommit(ctx, func(ctx context.Context) { events = append(events, "orders") })
+
+		// Do другого менеджера открывает свою транзакцию, а не точку сохранения
+		return DoVoid(ctx, billing, func(ctx context.Context) error {
+			AfterCommit(ctx, func(ctx context.Context) { events = append(events, "billing") })
+
+			// В одном контексте доступны транзакции обеих БД
+			ordersTx, err := TxFromContext(ctx, orders)
+			require.NoError(t, err)
+			billingTx, err := TxFromContext(ctx, billing)
+			require.NoError(t, err)
+			assert.NotSame(t, ordersTx, billingTx)
+
+			_, err = ordersTx.ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)")
+			require.NoError(t, err)
+			_, err = billingTx.ExecContext(ctx, "INSERT INTO invoices (order_id) VALUES (1)")
+			return err
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"billing", "orders"}, events)
+	assert.NoError(t, ordersMock.ExpectationsWereMet())
+	assert.NoError(t, billingMock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_SeparateManagersOnSameDB(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	first := NewTransactionManager(db)
+	second := NewTransactionManager(db)
+
+	err = DoVoid(context.Background(), first, func(ctx context.Context) error {
+		// Транзакция первого менеджера не видна второму
+		_, err := TxFromContext(ctx, second)
+		assert.EqualError(t, err, "cannot find transaction")
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSavepointTransactionManager(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT trx_1")
+	expectExec(mock, "INSERT INTO orders")
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "RELEASE SAVEPOINT sp_1")
+	expectExec(mock, "RELEASE SAVEPOINT trx_1")
+	expectExec(mock, "SAVEPOINT trx_2")
+	expectExec(mock, "ROLLBACK TO SAVEPOINT trx_2")
+	expectExec(mock, "DELETE FROM orders")
+	mock.ExpectRollback()
+
+	outer, err := db.Begin()
+	require.NoError(t, err)
+	trm := NewSavepointTransactionManager(outer)
+
+	// Коммит Do освобождает точку сохранения, не завершая внешнюю транзакцию
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		tx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+		assert.Same(t, outer, tx.Tx)
+		if _, err := tx.ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)"); err != nil {
+			return err
+		}
+		return DoVoid(ctx, trm, func(ctx context.Context) error { return nil })
+	})
+	require.NoError(t, err)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error { return assert.AnError })
+	require.ErrorIs(t, err, assert.AnError)
+
+	// Querier вне Do тоже работает во внешней транзакции
+	_, err = NewQuerier(trm).ExecContext(context.Background(), "DELETE FROM orders")
+	require.NoError(t, err)
+
+	require.NoError(t, outer.Rollback())
+	assert.Equal(t, int64(1), trm.Metrics().Commits)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
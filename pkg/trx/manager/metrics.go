@@ -0,0 +1,143 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+// ErrTransactionTimeout - транзакция превысила максимальную длительность (WithTimeout)
+var ErrTransactionTimeout = errors.New("transaction timeout exceeded")
+
+// WithTimeout ограничивает длительность новой транзакции: по истечении d
+// контекст транзакции отменяется, и она откатывается с ErrTransactionTimeout.
+// При повторе (WithRetry) ограничение действует на каждую попытку отдельно.
+// Вложенные и присоединившиеся вызовы ограничены временем внешней транзакции.
+func WithTimeout(d time.Duration) Option {
+	return func(s *settings) {
+		s.timeout = d
+	}
+}
+
+// WithSlowThreshold включает отчет о медленных транзакциях: если новая
+// транзакция длилась не меньше d, в лог пишется предупреждение с местом
+// вызова Do и количеством выполненных запросов
+func WithSlowThreshold(d time.Duration) Option {
+	return func(s *settings) {
+		s.slowThreshold = d
+	}
+}
+
+// WithLogger задает логгер для отчетов о медленных транзакциях.
+// По умолчанию используется slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *settings) {
+		s.logger = logger
+	}
+}
+
+// WithClock задает источник времени для измерения длительности транзакций
+func WithClock(c clock.Clock) Option {
+	return func(s *settings) {
+		s.clock = c
+	}
+}
+
+// Metrics - снимок счетчиков транзакций менеджера.
+// Учитываются только транзакции, открытые менеджером (не вложенные Do)
+type Metrics struct {
+	Commits          int64         // Закоммиченные транзакции
+	Rollbacks        int64         // Откаченные транзакции, включая неудачные коммиты
+	CommitDuration   time.Duration // Суммарная длительность закоммиченных транзакций
+	RollbackDuration time.Duration // Суммарная длительность откаченных транзакций
+	Slow             int64         // Транзакции дольше WithSlowThreshold
+	TimedOut         int64         // Транзакции, отмененные по WithTimeout
+}
+
+// metrics - счетчики транзакций менеджера
+type metrics struct {
+	commits          atomic.Int64
+	rollbacks        atomic.Int64
+	commitDuration   atomic.Int64
+	rollbackDuration atomic.Int64
+	slow             atomic.Int64
+	timedOut         atomic.Int64
+}
+
+// Metrics возвращает снимок счетчиков транзакций менеджера
+func (trm *Manager[T]) Metrics() Metrics {
+	return Metrics{
+		Commits:          trm.metrics.commits.Load(),
+		Rollbacks:        trm.metrics.rollbacks.Load(),
+		CommitDuration:   time.Duration(trm.metrics.commitDuration.Load()),
+		RollbackDuration: time.Duration(trm.metrics.rollbackDuration.Load()),
+		Slow:             trm.metrics.slow.Load(),
+		TimedOut:         trm.metrics.timedOut.Load(),
+	}
+}
+
+// finish учитывает завершенную транзакцию в счетчиках и при необходимости
+// сообщает о медленной транзакции
+func (trm *Manager[T]) finish(ctx context.Context, s settings, start time.Time, tx Tx, committed bool) {
+	elapsed := clock.OrDefault(s.clock).Now().Sub(start)
+	if committed {
+		trm.metrics.commits.Add(1)
+		trm.metrics.commitDuration.Add(int64(elapsed))
+	} else {
+		trm.metrics.rollbacks.Add(1)
+		trm.metrics.rollbackDuration.Add(int64(elapsed))
+	}
+
+	if s.slowThreshold <= 0 || elapsed < s.slowThreshold {
+		return
+	}
+	trm.metrics.slow.Add(1)
+
+	attrs := []any{
+		"duration", elapsed,
+		"threshold", s.slowThreshold,
+		"caller", s.caller,
+		"committed", committed,
+	}
+	if counter, ok := tx.(StatementCounter); ok {
+		attrs = append(attrs, "statements", counter.Statements())
+	}
+
+	logger := s.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.WarnContext(ctx, "slow transaction", attrs...)
+}
+
+// packageDir - каталог исходников пакета, кадры из которого пропускаются
+// при поиске места вызова Do
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerOutsidePackage возвращает "файл:строка" первого кадра стека вне пакета
+// (тесты пакета считаются внешним кодом)
+func callerOutsidePackage() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != packageDir || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package gotrxmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-utils/pkg/clock"
+)
+
+func TestWithTimeout_CancelsTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db, WithTimeout(10*time.Millisecond))
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	assert.ErrorIs(t, err, ErrTransactionTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), trm.Metrics().TimedOut)
+	assert.Equal(t, int64(1), trm.Metrics().Rollbacks)
+	// database/sql откатывает транзакцию отмененного контекста асинхронно
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, time.Millisecond)
+}
+
+func TestWithTimeout_PerCallOverride(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db, WithTimeout(time.Millisecond))
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.Greater(t, time.Until(deadline), 10*time.Second)
+		return nil
+	}, WithTimeout(time.Minute))
+
+	assert.NoError(t, err)
+	assert.Zero(t, trm.Metrics().TimedOut)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithSlowThreshold_LogsSlowTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE accounts").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT balance").WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(10))
+	mock.ExpectCommit()
+
+	var logs bytes.Buffer
+	clk := clock.NewFake(time.Now())
+	trm := NewTransactionManager(db,
+		WithSlowThreshold(time.Second),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+		WithClock(clk),
+	)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		tx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - 1")
+		require.NoError(t, err)
+		var balance int
+		require.NoError(t, tx.QueryRowContext(ctx, "SELECT balance FROM accounts").Scan(&balance))
+
+		clk.Advance(2 * time.Second)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var entry struct {
+		Msg        string `json:"msg"`
+		Caller     string `json:"caller"`
+		Statements int    `json:"statements"`
+		Committed  bool   `json:"committed"`
+	}
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "slow transaction", entry.Msg)
+	assert.True(t, strings.Contains(entry.Caller, "metrics_test.go:"), "caller should point to the Do call site, got %s", entry.Caller)
+	assert.Equal(t, 2, entry.Statements)
+	assert.True(t, entry.Committed)
+
+	assert.Equal(t, int64(1), trm.Metrics().Slow)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithSlowThreshold_FastTransactionNotLogged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	var logs bytes.Buffer
+	trm := NewTransactionManager(db,
+		WithSlowThreshold(time.Second),
+		WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+		WithClock(clock.NewFake(time.Now())),
+	)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error { return nil })
+
+	assert.NoError(t, err)
+	assert.Empty(t, logs.String())
+	assert.Zero(t, trm.Metrics().Slow)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestManager_Metrics(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Вложенный Do не считается отдельной транзакцией
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "RELEASE SAVEPOINT sp_1")
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	clk := clock.NewFake(time.Now())
+	trm := NewTransactionManager(db, WithClock(clk))
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		clk.Advance(time.Second)
+		return DoVoid(ctx, trm, func(ctx context.Context) error { return nil })
+	})
+	require.NoError(t, err)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		clk.Advance(3 * time.Second)
+		return assert.AnError
+	})
+	require.ErrorIs(t, err, assert.AnError)
+
+	assert.Equal(t, Metrics{
+		Commits:          1,
+		Rollbacks:        1,
+		CommitDuration:   time.Second,
+		RollbackDuration: 3 * time.Second,
+	}, trm.Metrics())
+}
@@ -0,0 +1,81 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+
+	"go-utils/pkg/clock"
+	"go-utils/pkg/retry"
+)
+
+// Option - настройка транзакции.
+// Переданные в NewTransactionManager опции задают значения по умолчанию
+// для всех вызовов Do менеджера, переданные в Do - переопределяют их для
+// конкретного вызова.
+type Option func(*settings)
+
+// settings - итоговые настройки, с которыми выполняется Do
+type settings struct {
+	txOptions   sql.TxOptions // Уровень изоляции и режим только для чтения
+	dialect     Dialect       // SQL для точек сохранения вложенных Do
+	propagation Propagation   // Правило работы с уже открытой транзакцией
+
+	retry           *retry.Config    // Повтор транзакции при временных ошибках
+	retryClassifier func(error) bool // Какие ошибки считать временными
+
+	panicAsError bool // Возвращать PanicError вместо повторной паники
+
+	hookErrorHandler func(ctx context.Context, err error) // Обработчик паник в хуках
+
+	replicas *ReplicaSet // Реплики для чтения (только для NewTransactionManager)
+
+	timeout       time.Duration // Максимальная длительность новой транзакции
+	slowThreshold time.Duration // Порог отчета о медленной транзакции
+	logger        *slog.Logger  // Логгер отчетов о медленных транзакциях
+	clock         clock.Clock   // Источник времени для измерения длительности
+	caller        string        // Место вызова Do, определяется только при WithSlowThreshold
+
+	leakDetection bool // Сообщать об использовании транзакции после завершения Do
+}
+
+// defaultSettings - настройки менеджера, если опции не заданы
+func defaultSettings() settings {
+	return settings{
+		dialect:     DialectPostgres,
+		propagation: PropagationNested,
+
+		hookErrorHandler: defaultHookErrorHandler,
+	}
+}
+
+// WithIsolation задает уровень изоляции транзакции
+func WithIsolation(level sql.IsolationLevel) Option {
+	return func(s *settings) {
+		s.txOptions.Isolation = level
+	}
+}
+
+// WithReadOnly открывает транзакцию только для чтения
+func WithReadOnly() Option {
+	return func(s *settings) {
+		s.txOptions.ReadOnly = true
+	}
+}
+
+// WithReadWrite снимает режим только для чтения, заданный по умолчанию для менеджера
+func WithReadWrite() Option {
+	return func(s *settings) {
+		s.txOptions.ReadOnly = false
+	}
+}
+
+// newSettings применяет опции поверх базовых настроек
+func newSettings(base settings, opts []Option) settings {
+	s := base
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// txOptionsRecorder оборачивает соединение sqlmock и запоминает опции,
+// с которыми database/sql начинает транзакции (sqlmock сам их не проверяет)
+type txOptionsRecorder struct {
+	mu   sync.Mutex
+	opts []driver.TxOptions
+}
+
+func (r *txOptionsRecorder) last() driver.TxOptions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.opts[len(r.opts)-1]
+}
+
+// newRecordingMock создает *sql.DB поверх sqlmock, который записывает TxOptions
+func newRecordingMock(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *txOptionsRecorder) {
+	t.Helper()
+
+	dsn := fmt.Sprintf("recording_%s", t.Name())
+	mockDB, mock, err := sqlmock.NewWithDSN(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { mockDB.Close() })
+
+	rec := &txOptionsRecorder{}
+	db := sql.OpenDB(&recordingConnector{dsn: dsn, drv: mockDB.Driver(), rec: rec})
+	t.Cleanup(func() { db.Close() })
+
+	return db, mock, rec
+}
+
+type recordingConnector struct {
+	dsn string
+	drv driver.Driver
+	rec *txOptionsRecorder
+}
+
+func (c *recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.drv.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: conn, rec: c.rec}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+type recordingConn struct {
+	driver.Conn
+	rec *txOptionsRecorder
+}
+
+func (c *recordingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.rec.mu.Lock()
+	c.rec.opts = append(c.rec.opts, opts)
+	c.rec.mu.Unlock()
+
+	return c.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+}
+
+func (c *recordingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.Conn.(driver.ExecerContext).ExecContext(ctx, query, args)
+}
+
+func (c *recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.Conn.(driver.QueryerContext).QueryContext(ctx, query, args)
+}
+
+func TestTransactionManager_Do_DefaultTxOptions(t *testing.T) {
+	db, mock, rec := newRecordingMock(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	_, err := trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return nil, nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelDefault)}, rec.last())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_WithOptions(t *testing.T) {
+	db, mock, rec := newRecordingMock(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	_, err := trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return nil, nil
+	}, WithIsolation(sql.LevelSerializable), WithReadOnly())
+
+	assert.NoError(t, err)
+	assert.Equal(t, driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable), ReadOnly: true}, rec.last())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_ManagerDefaults(t *testing.T) {
+	db, mock, rec := newRecordingMock(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	// Менеджер по умолчанию открывает SERIALIZABLE транзакции только для чтения
+	trm := NewTransactionManager(db, WithIsolation(sql.LevelSerializable), WithReadOnly())
+	noop := func(ctx context.Context) (any, error) { return nil, nil }
+
+	_, err := trm.Do(context.Background(), noop)
+	assert.NoError(t, err)
+	assert.Equal(t, driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable), ReadOnly: true}, rec.last())
+
+	// Опции вызова переопределяют значения по умолчанию
+	_, err = trm.Do(context.Background(), noop, WithIsolation(sql.LevelReadCommitted), WithReadWrite())
+	assert.NoError(t, err)
+	assert.Equal(t, driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelReadCommitted)}, rec.last())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,45 @@
+package gotrxmanager
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError - паника внутри f, перехваченная Do после отката транзакции.
+// Возвращается вместо повторной паники, если задан WithPanicError.
+type PanicError struct {
+	Value any    // Значение, переданное в panic
+	Stack []byte // Стек горутины в момент паники
+}
+
+// Error возвращает текст ошибки со значением паники
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v", e.Value)
+}
+
+// Unwrap возвращает значение паники, если это ошибка
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// WithPanicError - при панике в f откатить транзакцию и вернуть *PanicError
+// вместо повторной паники (по умолчанию паника пробрасывается дальше)
+func WithPanicError() Option {
+	return func(s *settings) {
+		s.panicAsError = true
+	}
+}
+
+// callRecovered вызывает f, перехватывая панику
+func callRecovered(ctx context.Context, f func(ctx context.Context) (any, error)) (res any, p *PanicError, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+
+	res, err = f(ctx)
+	return res, nil, err
+}
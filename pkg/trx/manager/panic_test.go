@@ -0,0 +1,151 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionManager_Do_PanicRollsBackAndRepanics(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+			panic("boom")
+		})
+	}()
+
+	assert.Equal(t, "boom", recovered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_RepanicsOriginalValue(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+
+	var recovered any
+	func() {
+		defer func() { recovered = recover() }()
+		trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+			panic(http.ErrAbortHandler)
+		})
+	}()
+
+	// Вызывающий код сравнивает значение паники с известными ошибками
+	assert.Equal(t, http.ErrAbortHandler, recovered)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_RequiresNewPanicNotWrappedTwice(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+	outer := NewTransactionManager(db, WithPanicError())
+
+	_, err = outer.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return trm.Do(ctx, func(ctx context.Context) (any, error) {
+			panic("boom")
+		}, WithPropagation(PropagationRequiresNew))
+	})
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Equal(t, "panic in transaction: boom", panicErr.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_PanicAsError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db, WithPanicError())
+	cause := errors.New("nil pointer")
+
+	result, err := trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		panic(cause)
+	})
+
+	var panicErr *PanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Nil(t, result)
+	assert.Equal(t, cause, panicErr.Value)
+	assert.ErrorIs(t, err, cause)
+	assert.Contains(t, string(panicErr.Stack), "TestTransactionManager_Do_PanicAsError")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_NestedPanicRollsBackOuter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db, WithPanicError())
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return trm.Do(ctx, func(ctx context.Context) (any, error) {
+			panic("boom")
+		})
+	})
+
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_ContextCancelledRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// f не заметила отмену и вернула успех, но коммитить такой результат нельзя
+	_, err = trm.Do(ctx, func(ctx context.Context) (any, error) {
+		cancel()
+		return "done", nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,61 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+)
+
+// Propagation - правило, по которому Do ведет себя, если в контексте
+// уже есть транзакция (аналог propagation в Spring @Transactional)
+type Propagation int
+
+const (
+	// PropagationRequired - присоединиться к текущей транзакции или открыть новую.
+	// Ошибка присоединившегося вызова помечает всю транзакцию на откат;
+	// пометку снимает откат к точке сохранения вложенного Do, внутри которого он выполнялся.
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew - всегда открыть новую транзакцию,
+	// внешняя приостанавливается до ее завершения
+	PropagationRequiresNew
+	// PropagationNested - выполнить внутри точки сохранения текущей транзакции
+	// или открыть новую. Используется по умолчанию.
+	PropagationNested
+	// PropagationSupports - присоединиться к текущей транзакции,
+	// а без нее выполнить f вне транзакции
+	PropagationSupports
+	// PropagationMandatory - присоединиться к текущей транзакции,
+	// а без нее вернуть ErrNoTransaction
+	PropagationMandatory
+	// PropagationNever - выполнить f вне транзакции,
+	// а при открытой транзакции вернуть ErrTransactionExists
+	PropagationNever
+)
+
+var (
+	// ErrNoTransaction - для PropagationMandatory нет открытой транзакции
+	ErrNoTransaction = errors.New("no existing transaction found for propagation mandatory")
+	// ErrTransactionExists - для PropagationNever уже есть открытая транзакция
+	ErrTransactionExists = errors.New("existing transaction found for propagation never")
+	// ErrRollbackOnly - транзакция помечена на откат присоединившимся вызовом,
+	// поэтому вместо коммита выполнен откат
+	ErrRollbackOnly = errors.New("transaction has been marked as rollback-only")
+)
+
+// WithPropagation задает правило работы с уже открытой транзакцией
+func WithPropagation(p Propagation) Option {
+	return func(s *settings) {
+		s.propagation = p
+	}
+}
+
+// doJoined выполняет f в текущей транзакции без точки сохранения.
+// Откатить отдельно работу f нельзя, поэтому при ошибке вся транзакция
+// помечается на откат.
+func (trm *Manager[T]) doJoined(ctx context.Context, t *transaction, f func(ctx context.Context) (any, error)) (any, error) {
+	res, err := f(trm.withTransaction(ctx, t))
+	if err != nil {
+		t.rollbackOnly = true
+		return nil, err
+	}
+	return res, nil
+}
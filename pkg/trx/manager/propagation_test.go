@@ -0,0 +1,188 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagation_RequiredJoins(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Присоединение не создает ни новой транзакции, ни точки сохранения
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db, WithPropagation(PropagationRequired))
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		outerTx, _ := TxFromContext(ctx, trm)
+
+		return trm.Do(ctx, func(ctx context.Context) (any, error) {
+			innerTx, err := TxFromContext(ctx, trm)
+			require.NoError(t, err)
+			assert.Same(t, outerTx, innerTx)
+			return nil, nil
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPropagation_RequiredMarksRollbackOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db, WithPropagation(PropagationRequired))
+	innerErr := errors.New("inner failed")
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		// Внешний вызов игнорирует ошибку, но транзакция уже помечена на откат
+		_, err := trm.Do(ctx, func(ctx context.Context) (any, error) {
+			return nil, innerErr
+		})
+		assert.ErrorIs(t, err, innerErr)
+		return nil, nil
+	})
+
+	assert.ErrorIs(t, err, ErrRollbackOnly)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPropagation_RequiresNewSuspendsOuter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	innerErr := errors.New("inner failed")
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		outerTx, _ := TxFromContext(ctx, trm)
+
+		_, err := trm.Do(ctx, func(ctx context.Context) (any, error) {
+			// Внутри активна новая транзакция
+			innerTx, err := TxFromContext(ctx, trm)
+			require.NoError(t, err)
+			assert.NotSame(t, outerTx, innerTx)
+			return nil, innerErr
+		}, WithPropagation(PropagationRequiresNew))
+		assert.ErrorIs(t, err, innerErr)
+
+		// После завершения внутренней снова активна внешняя
+		tx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+		assert.Same(t, outerTx, tx)
+		return nil, nil
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPropagation_Supports(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	trm := NewTransactionManager(db, WithPropagation(PropagationSupports))
+
+	// Без транзакции f выполняется вне транзакции
+	result, err := trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		_, err := TxFromContext(ctx, trm)
+		assert.Error(t, err)
+		return "no tx", nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "no tx", result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPropagation_Mandatory(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	noop := func(ctx context.Context) (any, error) { return nil, nil }
+
+	_, err = trm.Do(context.Background(), noop, WithPropagation(PropagationMandatory))
+	assert.ErrorIs(t, err, ErrNoTransaction)
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return trm.Do(ctx, noop, WithPropagation(PropagationMandatory))
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPropagation_Never(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+	noop := func(ctx context.Context) (any, error) { return "ok", nil }
+
+	result, err := trm.Do(context.Background(), noop, WithPropagation(PropagationNever))
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", result)
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return trm.Do(ctx, noop, WithPropagation(PropagationNever))
+	})
+	assert.ErrorIs(t, err, ErrTransactionExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPropagation_RequiredInsideNestedContained(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "ROLLBACK TO SAVEPOINT sp_1")
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	innerErr := errors.New("inner failed")
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		_, err := trm.Do(ctx, func(ctx context.Context) (any, error) {
+			return trm.Do(ctx, func(ctx context.Context) (any, error) {
+				return nil, innerErr
+			}, WithPropagation(PropagationRequired))
+		})
+		// Работа присоединившегося вызова отменена откатом к точке сохранения,
+		// поэтому внешняя транзакция может закоммититься
+		assert.ErrorIs(t, err, innerErr)
+		return nil, nil
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
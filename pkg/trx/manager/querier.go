@@ -0,0 +1,70 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier выполняет запросы в транзакции менеджера из контекста,
+// а если ее нет - напрямую через *sql.DB.
+// Репозиториям не нужно проверять наличие транзакции самим.
+//
+// Если менеджеру заданы реплики (WithReplicas), QueryContext и QueryRowContext
+// вне транзакции выполняются на реплике только с контекстом ReadFromReplica,
+// а по умолчанию - в основной БД.
+type Querier struct {
+	trm *TransactionManager
+	src *sqlBeginner // Основная БД и реплики менеджера
+}
+
+// NewQuerier создает Querier поверх менеджера транзакций.
+// Менеджер должен быть создан через NewTransactionManager.
+func NewQuerier(trm *TransactionManager) *Querier {
+	return &Querier{trm: trm, src: sqlBeginnerOf(trm, "Querier")}
+}
+
+// conn - общий набор методов *sql.DB и *sql.Tx
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// ExecContext выполняет запрос без возврата строк
+func (q *Querier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return q.conn(ctx).ExecContext(ctx, query, args...)
+}
+
+// QueryContext выполняет запрос, возвращающий строки
+func (q *Querier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return q.readConn(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext выполняет запрос, возвращающий не более одной строки
+func (q *Querier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return q.readConn(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// PrepareContext подготавливает запрос. Подготовленный в транзакции запрос
+// действует только до ее завершения.
+func (q *Querier) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return q.conn(ctx).PrepareContext(ctx, query)
+}
+
+// conn возвращает транзакцию из контекста или БД менеджера
+func (q *Querier) conn(ctx context.Context) conn {
+	if t, ok := q.trm.transactionFromContext(ctx); ok {
+		return t.tx.(*SQLTx)
+	}
+	return q.src.conn()
+}
+
+// readConn возвращает транзакцию из контекста, а вне транзакции - реплику
+// (для контекстов ReadFromReplica) или основную БД
+func (q *Querier) readConn(ctx context.Context) conn {
+	if t, ok := q.trm.transactionFromContext(ctx); ok {
+		return t.tx.(*SQLTx)
+	}
+	return q.src.readConn(ctx)
+}
@@ -0,0 +1,69 @@
+package gotrxmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuerier_UsesTransactionFromContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO orders").WithArgs(1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT count").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+	q := NewQuerier(trm)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		_, err := q.ExecContext(ctx, "INSERT INTO orders (id) VALUES ($1)", 1)
+		require.NoError(t, err)
+
+		var count int
+		require.NoError(t, q.QueryRowContext(ctx, "SELECT count(*) FROM orders").Scan(&count))
+		assert.Equal(t, 1, count)
+
+		// Откат доказывает, что запросы шли в транзакции
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQuerier_FallsBackToDB(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Ни Begin, ни Commit не ожидаются
+	mock.ExpectQuery("SELECT id FROM orders").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	mock.ExpectPrepare("UPDATE orders")
+
+	q := NewQuerier(NewTransactionManager(db))
+
+	rows, err := q.QueryContext(context.Background(), "SELECT id FROM orders")
+	require.NoError(t, err)
+	var ids []int
+	for rows.Next() {
+		var id int
+		require.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []int{1, 2}, ids)
+
+	stmt, err := q.PrepareContext(context.Background(), "UPDATE orders SET status = $1")
+	require.NoError(t, err)
+	assert.NotNil(t, stmt)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
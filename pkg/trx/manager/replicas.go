@@ -0,0 +1,206 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go-utils/pkg/clock"
+)
+
+// Balancer - стратегия выбора реплики для чтения
+type Balancer int
+
+const (
+	RoundRobin       Balancer = iota // Реплики по очереди
+	LeastConnections                 // Реплика с наименьшим числом занятых соединений
+)
+
+// defaultHealthCheckTimeout - таймаут Ping реплики, если он не задан
+const defaultHealthCheckTimeout = time.Second
+
+// ReplicaConfig - настройки набора реплик
+type ReplicaConfig struct {
+	Replicas            []*sql.DB
+	Balancer            Balancer      // Стратегия выбора реплики, по умолчанию RoundRobin
+	HealthCheckInterval time.Duration // Период фоновой проверки реплик, 0 - без фоновой проверки
+	HealthCheckTimeout  time.Duration // Таймаут проверки одной реплики, по умолчанию 1s
+	Clock               clock.Clock   // Источник времени, по умолчанию реальные часы
+}
+
+var (
+	// primaryKey - ключ контекста, закрепляющего чтения за основной БД
+	primaryKey = &trxManagerKey{name: "primary"}
+	// replicaKey - ключ контекста, разрешающего чтения Querier с реплик
+	replicaKey = &trxManagerKey{name: "replica"}
+)
+
+// NewReplicaSet создает набор реплик. Если задан HealthCheckInterval,
+// реплики проверяются в фоне до вызова Close.
+// Недоступные реплики исключаются из выбора до следующей успешной проверки.
+// Без фоновой проверки реплики исключаются только явным вызовом CheckHealth:
+// иначе после единичной ошибки вернуть реплику было бы некому.
+func NewReplicaSet(config ReplicaConfig) *ReplicaSet {
+	rs := &ReplicaSet{
+		balancer: config.Balancer,
+		timeout:  config.HealthCheckTimeout,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if rs.timeout <= 0 {
+		rs.timeout = defaultHealthCheckTimeout
+	}
+	for _, db := range config.Replicas {
+		r := &replica{db: db}
+		r.healthy.Store(true)
+		rs.replicas = append(rs.replicas, r)
+	}
+
+	if config.HealthCheckInterval <= 0 {
+		close(rs.done)
+		return rs
+	}
+	rs.backgroundCheck = true
+
+	ticker := clock.OrDefault(config.Clock).NewTicker(config.HealthCheckInterval)
+	go func() {
+		defer close(rs.done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				rs.CheckHealth(context.Background())
+			case <-rs.stop:
+				return
+			}
+		}
+	}()
+	return rs
+}
+
+// WithReplicas направляет читающие запросы менеджера на реплики:
+// новые транзакции только для чтения (WithReadOnly) и чтения Querier вне
+// транзакции с контекстом ReadFromReplica.
+// Если все реплики недоступны, используется основная БД.
+// Опция действует только при передаче в NewTransactionManager.
+func WithReplicas(rs *ReplicaSet) Option {
+	return func(s *settings) {
+		s.replicas = rs
+	}
+}
+
+// PinPrimary возвращает контекст, чтения с которым выполняются на основной БД.
+// Нужен, чтобы прочитать только что записанные данные (read-your-writes),
+// не дожидаясь репликации.
+func PinPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey, true)
+}
+
+// ReadFromReplica возвращает контекст, запросы Querier с которым вне транзакции
+// выполняются на реплике. Без него Querier отправляет все запросы в основную БД:
+// по QueryContext нельзя отличить чтение от INSERT ... RETURNING.
+// PinPrimary имеет приоритет над ReadFromReplica.
+func ReadFromReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, replicaKey, true)
+}
+
+// readsFromReplica сообщает, разрешены ли контексту чтения с реплик
+func readsFromReplica(ctx context.Context) bool {
+	allowed, _ := ctx.Value(replicaKey).(bool)
+	return allowed
+}
+
+// isPinnedToPrimary сообщает, закреплены ли чтения контекста за основной БД
+func isPinnedToPrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryKey).(bool)
+	return pinned
+}
+
+// CheckHealth проверяет все реплики через Ping и обновляет их доступность
+func (rs *ReplicaSet) CheckHealth(ctx context.Context) {
+	for _, r := range rs.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, rs.timeout)
+		err := r.db.PingContext(pingCtx)
+		cancel()
+		r.healthy.Store(err == nil)
+	}
+}
+
+// Healthy возвращает количество доступных реплик
+func (rs *ReplicaSet) Healthy() int {
+	n := 0
+	for _, r := range rs.replicas {
+		if r.healthy.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// Close останавливает фоновую проверку реплик. Сами БД не закрываются
+func (rs *ReplicaSet) Close() {
+	rs.closeOnce.Do(func() { close(rs.stop) })
+	<-rs.done
+}
+
+// pick выбирает доступную реплику для чтения.
+// Возвращает nil, если реплик нет, все недоступны или чтения контекста
+// закреплены за основной БД
+func (rs *ReplicaSet) pick(ctx context.Context) *replica {
+	if rs == nil || len(rs.replicas) == 0 || isPinnedToPrimary(ctx) {
+		return nil
+	}
+
+	// Начинаем со следующей по очереди реплики, чтобы при равной нагрузке
+	// LeastConnections тоже распределял запросы
+	start := int(rs.next.Add(1)-1) % len(rs.replicas)
+
+	var best *replica
+	bestInUse := 0
+	for i := range rs.replicas {
+		r := rs.replicas[(start+i)%len(rs.replicas)]
+		if !r.healthy.Load() {
+			continue
+		}
+		if rs.balancer == RoundRobin {
+			return r
+		}
+
+		inUse := r.db.Stats().InUse
+		if best == nil || inUse < bestInUse {
+			best, bestInUse = r, inUse
+		}
+	}
+	return best
+}
+
+// readDB возвращает реплику для чтения вне транзакции, если контекст
+// это разрешает (ReadFromReplica), или основную БД
+func (rs *ReplicaSet) readDB(ctx context.Context, primary *sql.DB) *sql.DB {
+	if !readsFromReplica(ctx) {
+		return primary
+	}
+	if r := rs.pick(ctx); r != nil {
+		return r.db
+	}
+	return primary
+}
+
+// beginReadOnly открывает транзакцию только для чтения на реплике.
+// Если открыть не удалось, возвращается false, а при фоновой проверке
+// реплика считается недоступной до следующей проверки
+func (rs *ReplicaSet) beginReadOnly(ctx context.Context, opts sql.TxOptions) (*sql.Tx, bool) {
+	r := rs.pick(ctx)
+	if r == nil {
+		return nil, false
+	}
+
+	tx, err := r.db.BeginTx(ctx, &opts)
+	if err != nil {
+		if rs.backgroundCheck && ctx.Err() == nil {
+			r.healthy.Store(false)
+		}
+		return nil, false
+	}
+	return tx, true
+}
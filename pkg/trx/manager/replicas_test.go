@@ -0,0 +1,237 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-utils/pkg/clock"
+)
+
+// newMockDB создает мок БД, проверяющий Ping
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+// readOnlyNoop - пустая транзакция только для чтения
+func readOnlyNoop(ctx context.Context, trm *TransactionManager) error {
+	return DoVoid(ctx, trm, func(ctx context.Context) error { return nil }, WithReadOnly())
+}
+
+func TestReplicas_ReadOnlyDoRoundRobin(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica1, replica1Mock := newMockDB(t)
+	replica2, replica2Mock := newMockDB(t)
+
+	replica1Mock.ExpectBegin()
+	replica1Mock.ExpectCommit()
+	replica2Mock.ExpectBegin()
+	replica2Mock.ExpectCommit()
+	replica1Mock.ExpectBegin()
+	replica1Mock.ExpectCommit()
+	// Пишущая транзакция всегда идет в основную БД
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit()
+
+	rs := NewReplicaSet(ReplicaConfig{Replicas: []*sql.DB{replica1, replica2}})
+	defer rs.Close()
+	trm := NewTransactionManager(primary, WithReplicas(rs))
+
+	for range 3 {
+		require.NoError(t, readOnlyNoop(context.Background(), trm))
+	}
+	require.NoError(t, DoVoid(context.Background(), trm, func(ctx context.Context) error { return nil }))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replica1Mock.ExpectationsWereMet())
+	assert.NoError(t, replica2Mock.ExpectationsWereMet())
+}
+
+func TestReplicas_PinPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit()
+	primaryMock.ExpectQuery("SELECT status").WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("paid"))
+
+	rs := NewReplicaSet(ReplicaConfig{Replicas: []*sql.DB{replica}})
+	defer rs.Close()
+	trm := NewTransactionManager(primary, WithReplicas(rs))
+	ctx := PinPrimary(context.Background())
+
+	require.NoError(t, readOnlyNoop(ctx, trm))
+
+	// PinPrimary важнее ReadFromReplica
+	var status string
+	require.NoError(t, NewQuerier(trm).QueryRowContext(ReadFromReplica(ctx), "SELECT status FROM orders").Scan(&status))
+	assert.Equal(t, "paid", status)
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_QuerierReadsFromReplica(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	replicaMock.ExpectQuery("SELECT id FROM orders").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	primaryMock.ExpectExec("UPDATE orders").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectQuery("INSERT INTO orders").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+	rs := NewReplicaSet(ReplicaConfig{Replicas: []*sql.DB{replica}})
+	defer rs.Close()
+	q := NewQuerier(NewTransactionManager(primary, WithReplicas(rs)))
+
+	var id int
+	require.NoError(t, q.QueryRowContext(ReadFromReplica(context.Background()), "SELECT id FROM orders").Scan(&id))
+	_, err := q.ExecContext(context.Background(), "UPDATE orders SET status = 'paid'")
+	require.NoError(t, err)
+	// Без ReadFromReplica запросы, возвращающие строки, идут в основную БД
+	require.NoError(t, q.QueryRowContext(context.Background(), "INSERT INTO orders DEFAULT VALUES RETURNING id").Scan(&id))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_HealthCheckExcludesReplica(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica1, replica1Mock := newMockDB(t)
+	replica2, replica2Mock := newMockDB(t)
+
+	replica1Mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	replica2Mock.ExpectPing()
+	replica2Mock.ExpectBegin()
+	replica2Mock.ExpectCommit()
+	replica2Mock.ExpectBegin()
+	replica2Mock.ExpectCommit()
+
+	rs := NewReplicaSet(ReplicaConfig{Replicas: []*sql.DB{replica1, replica2}})
+	defer rs.Close()
+	trm := NewTransactionManager(primary, WithReplicas(rs))
+
+	rs.CheckHealth(context.Background())
+	assert.Equal(t, 1, rs.Healthy())
+
+	require.NoError(t, readOnlyNoop(context.Background(), trm))
+	require.NoError(t, readOnlyNoop(context.Background(), trm))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replica1Mock.ExpectationsWereMet())
+	assert.NoError(t, replica2Mock.ExpectationsWereMet())
+}
+
+func TestReplicas_FallbackToPrimary(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	// Реплика не открыла транзакцию - чтение уходит в основную БД,
+	// а реплика исключается до следующей фоновой проверки
+	replicaMock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit()
+	primaryMock.ExpectQuery("SELECT id").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	rs := NewReplicaSet(ReplicaConfig{
+		Replicas:            []*sql.DB{replica},
+		HealthCheckInterval: time.Hour,
+		Clock:               clock.NewFake(time.Now()),
+	})
+	defer rs.Close()
+	trm := NewTransactionManager(primary, WithReplicas(rs))
+
+	require.NoError(t, readOnlyNoop(context.Background(), trm))
+	assert.Equal(t, 0, rs.Healthy())
+
+	var id int
+	require.NoError(t, NewQuerier(trm).QueryRowContext(ReadFromReplica(context.Background()), "SELECT id FROM orders").Scan(&id))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_BeginErrorWithoutHealthCheck(t *testing.T) {
+	primary, primaryMock := newMockDB(t)
+	replica, replicaMock := newMockDB(t)
+
+	// Без фоновой проверки реплику после ошибки никто не вернул бы,
+	// поэтому она остается в выборе и используется следующим чтением
+	replicaMock.ExpectBegin().WillReturnError(errors.New("connection reset"))
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectCommit()
+	replicaMock.ExpectBegin()
+	replicaMock.ExpectCommit()
+
+	rs := NewReplicaSet(ReplicaConfig{Replicas: []*sql.DB{replica}})
+	defer rs.Close()
+	trm := NewTransactionManager(primary, WithReplicas(rs))
+
+	require.NoError(t, readOnlyNoop(context.Background(), trm))
+	assert.Equal(t, 1, rs.Healthy())
+	require.NoError(t, readOnlyNoop(context.Background(), trm))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
+func TestReplicas_LeastConnections(t *testing.T) {
+	primary, _ := newMockDB(t)
+	replica1, replica1Mock := newMockDB(t)
+	replica2, replica2Mock := newMockDB(t)
+
+	replica1Mock.ExpectBegin()
+	replica2Mock.ExpectBegin()
+	replica2Mock.ExpectCommit()
+	replica2Mock.ExpectBegin()
+	replica2Mock.ExpectCommit()
+	replica1Mock.ExpectRollback()
+
+	rs := NewReplicaSet(ReplicaConfig{Replicas: []*sql.DB{replica1, replica2}, Balancer: LeastConnections})
+	defer rs.Close()
+	trm := NewTransactionManager(primary, WithReplicas(rs))
+
+	// Занимаем соединение первой реплики
+	busy, err := replica1.Begin()
+	require.NoError(t, err)
+
+	require.NoError(t, readOnlyNoop(context.Background(), trm))
+	require.NoError(t, readOnlyNoop(context.Background(), trm))
+	require.NoError(t, busy.Rollback())
+
+	assert.NoError(t, replica1Mock.ExpectationsWereMet())
+	assert.NoError(t, replica2Mock.ExpectationsWereMet())
+}
+
+func TestReplicas_BackgroundHealthCheck(t *testing.T) {
+	replica, replicaMock := newMockDB(t)
+	clk := clock.NewFake(time.Now())
+
+	replicaMock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	replicaMock.ExpectPing()
+
+	rs := NewReplicaSet(ReplicaConfig{
+		Replicas:            []*sql.DB{replica},
+		HealthCheckInterval: time.Second,
+		Clock:               clk,
+	})
+	defer rs.Close()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	assert.Eventually(t, func() bool { return rs.Healthy() == 0 }, time.Second, time.Millisecond)
+
+	clk.Advance(time.Second)
+	assert.Eventually(t, func() bool { return rs.Healthy() == 1 }, time.Second, time.Millisecond)
+
+	rs.Close()
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
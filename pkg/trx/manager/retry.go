@@ -0,0 +1,163 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"go-utils/pkg/retry"
+)
+
+// WithRetry включает повтор всей транзакции, если она завершилась ошибкой,
+// которую классификатор (WithRetryClassifier) признает временной -
+// например, ошибкой сериализации или взаимоблокировкой.
+// f при повторе выполняется заново в новой транзакции, поэтому не должна
+// иметь побочных эффектов вне БД. Ошибки коммита не повторяются никогда:
+// после отправки COMMIT результат транзакции неизвестен.
+// Повтор действует только для вызовов, открывающих новую транзакцию.
+// MaxAttempts < 1 означает одну попытку без повторов.
+func WithRetry(config retry.Config) Option {
+	// Без попыток retry.RetryCtx не вызвал бы f и вернул бы успех
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	return func(s *settings) {
+		s.retry = &config
+	}
+}
+
+// WithRetryClassifier задает функцию, решающую, можно ли повторить
+// транзакцию после ошибки. По умолчанию используется IsRetryableError.
+func WithRetryClassifier(classifier func(error) bool) Option {
+	return func(s *settings) {
+		s.retryClassifier = classifier
+	}
+}
+
+// Коды ошибок PostgreSQL (SQLSTATE), после которых транзакцию можно повторить
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// Коды ошибок MySQL, после которых транзакцию можно повторить
+const (
+	mysqlLockWaitTimeout = 1205
+	mysqlDeadlock        = 1213
+)
+
+// sqlStater реализуется ошибками драйверов PostgreSQL (pgconn.PgError, pq.Error)
+type sqlStater interface {
+	SQLState() string
+}
+
+// errorNumberer реализуется ошибками, сообщающими числовой код ошибки MySQL
+type errorNumberer interface {
+	ErrorNumber() uint16
+}
+
+// mysqlErrorRe разбирает текст ошибки драйвера go-sql-driver/mysql:
+// "Error 1213 (40001): Deadlock found..." или "Error 1213: Deadlock found...".
+// *mysql.MySQLError хранит код в поле, а не в методе, поэтому распознается по тексту
+var mysqlErrorRe = regexp.MustCompile(`^Error (\d+)(?: \([0-9A-Z]{5}\))?: `)
+
+// IsPostgresRetryable сообщает, является ли err ошибкой PostgreSQL
+// serialization_failure (40001) или deadlock_detected (40P01).
+// Ошибка драйвера распознается по методу SQLState() string.
+func IsPostgresRetryable(err error) bool {
+	var e sqlStater
+	if !errors.As(err, &e) {
+		return false
+	}
+
+	switch e.SQLState() {
+	case pgSerializationFailure, pgDeadlockDetected:
+		return true
+	}
+	return false
+}
+
+// IsMySQLRetryable сообщает, является ли err ошибкой MySQL о взаимоблокировке (1213)
+// или превышении ожидания блокировки (1205).
+// Код берется из метода ErrorNumber() uint16, а для ошибок go-sql-driver/mysql -
+// из их текста. Ошибки других драйверов MySQL, не реализующих ErrorNumber,
+// нужно классифицировать своей функцией через WithRetryClassifier.
+func IsMySQLRetryable(err error) bool {
+	code, ok := mysqlErrorNumber(err)
+	return ok && (code == mysqlDeadlock || code == mysqlLockWaitTimeout)
+}
+
+// mysqlErrorNumber ищет код ошибки MySQL в дереве ошибок err
+func mysqlErrorNumber(err error) (int, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	if e, ok := err.(errorNumberer); ok {
+		return int(e.ErrorNumber()), true
+	}
+	if m := mysqlErrorRe.FindStringSubmatch(err.Error()); m != nil {
+		code, convErr := strconv.Atoi(m[1])
+		return code, convErr == nil
+	}
+
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return mysqlErrorNumber(e.Unwrap())
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if code, ok := mysqlErrorNumber(inner); ok {
+				return code, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// IsRetryableError - классификатор по умолчанию: ошибки PostgreSQL и MySQL,
+// после которых транзакцию можно выполнить заново
+func IsRetryableError(err error) bool {
+	return IsPostgresRetryable(err) || IsMySQLRetryable(err)
+}
+
+// commitError - ошибка коммита транзакции. Такие ошибки не повторяются
+type commitError struct {
+	err error
+}
+
+func (e *commitError) Error() string {
+	return fmt.Sprintf("cannot commit transaction with error: %s", e.err)
+}
+
+func (e *commitError) Unwrap() error {
+	return e.err
+}
+
+// doNewWithRetry открывает новую транзакцию и, если задан WithRetry,
+// повторяет ее целиком при временных ошибках
+func (trm *Manager[T]) doNewWithRetry(ctx context.Context, s settings, f func(ctx context.Context) (any, error)) (any, error) {
+	if s.retry == nil {
+		return trm.doNew(ctx, s, f)
+	}
+
+	classifier := s.retryClassifier
+	if classifier == nil {
+		classifier = IsRetryableError
+	}
+
+	config := *s.retry
+	config.RetryIf = func(err error) bool {
+		// После отправки COMMIT повторять нельзя
+		var ce *commitError
+		if errors.As(err, &ce) {
+			return false
+		}
+		return classifier(err)
+	}
+
+	return retry.RetryCtx(ctx, config, func(ctx context.Context) (any, error) {
+		return trm.doNew(ctx, s, f)
+	})
+}
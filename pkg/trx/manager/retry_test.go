@@ -0,0 +1,192 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-utils/pkg/retry"
+)
+
+// pgError имитирует ошибку драйвера PostgreSQL
+type pgError struct {
+	code string
+}
+
+func (e *pgError) Error() string    { return fmt.Sprintf("pq: error with SQLSTATE %s", e.code) }
+func (e *pgError) SQLState() string { return e.code }
+
+// mysqlError имитирует ошибку драйвера MySQL, сообщающую код методом
+type mysqlError struct {
+	number uint16
+}
+
+func (e *mysqlError) Error() string       { return fmt.Sprintf("mysql: server error %d", e.number) }
+func (e *mysqlError) ErrorNumber() uint16 { return e.number }
+
+var txRetryConfig = retry.Config{
+	MaxAttempts:  3,
+	InitialDelay: time.Millisecond,
+	MaxDelay:     time.Millisecond,
+}
+
+func TestTransactionManager_Do_RetriesSerializationFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE accounts").WillReturnError(&pgError{code: "40001"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE accounts").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db, WithRetry(txRetryConfig))
+
+	calls := 0
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		calls++
+		tx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - 1")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_DoesNotRetryOtherErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db, WithRetry(txRetryConfig))
+	uniqueViolation := &pgError{code: "23505"}
+
+	calls := 0
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		calls++
+		return uniqueViolation
+	})
+
+	assert.ErrorIs(t, err, uniqueViolation)
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_NeverRetriesCommit(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit().WillReturnError(&pgError{code: "40001"})
+
+	trm := NewTransactionManager(db, WithRetry(txRetryConfig))
+
+	calls := 0
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+
+	assert.EqualError(t, err, "cannot commit transaction with error: pq: error with SQLSTATE 40001")
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_ZeroRetryConfig(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE accounts").WillReturnError(&pgError{code: "40001"})
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db, WithRetry(retry.Config{}))
+
+	// Нулевая конфигурация - одна попытка, f выполняется, ошибка возвращается
+	calls := 0
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		calls++
+		tx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "UPDATE accounts SET balance = balance - 1")
+		return err
+	})
+
+	var pgErr *pgError
+	assert.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_CustomRetryClassifier(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	busy := errors.New("database is locked")
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db,
+		WithRetry(txRetryConfig),
+		WithRetryClassifier(func(err error) bool { return errors.Is(err, busy) }),
+	)
+
+	calls := 0
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return busy
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Postgres serialization failure", &pgError{code: "40001"}, true},
+		{"Postgres deadlock", &pgError{code: "40P01"}, true},
+		{"Postgres unique violation", &pgError{code: "23505"}, false},
+		{"Wrapped Postgres error", fmt.Errorf("update: %w", &pgError{code: "40001"}), true},
+		{"MySQL deadlock", errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), true},
+		{"MySQL lock wait timeout (old format)", errors.New("Error 1205: Lock wait timeout exceeded"), true},
+		{"Wrapped MySQL deadlock", fmt.Errorf("update: %w", errors.New("Error 1213 (40001): Deadlock found")), true},
+		{"MySQL error with ErrorNumber", fmt.Errorf("update: %w", &mysqlError{number: 1205}), true},
+		{"MySQL error with ErrorNumber not retryable", &mysqlError{number: 1062}, false},
+		{"Joined MySQL deadlock", errors.Join(errors.New("cleanup failed"), errors.New("Error 1213 (40001): Deadlock found")), true},
+		{"MySQL duplicate entry", errors.New("Error 1062 (23000): Duplicate entry"), false},
+		{"Plain error", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, IsRetryableError(tt.err))
+		})
+	}
+}
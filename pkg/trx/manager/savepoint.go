@@ -0,0 +1,91 @@
+package gotrxmanager
+
+import (
+	"context"
+	"fmt"
+)
+
+// Dialect - шаблоны SQL для работы с точками сохранения (savepoint).
+// Каждый шаблон содержит один %s, куда подставляется имя точки сохранения.
+type Dialect struct {
+	Savepoint           string // Создание точки сохранения
+	ReleaseSavepoint    string // Освобождение точки сохранения
+	RollbackToSavepoint string // Откат к точке сохранения
+}
+
+var (
+	// DialectPostgres - диалект PostgreSQL (используется по умолчанию)
+	DialectPostgres = Dialect{
+		Savepoint:           "SAVEPOINT %s",
+		ReleaseSavepoint:    "RELEASE SAVEPOINT %s",
+		RollbackToSavepoint: "ROLLBACK TO SAVEPOINT %s",
+	}
+
+	// DialectMySQL - диалект MySQL и MariaDB
+	DialectMySQL = Dialect{
+		Savepoint:           "SAVEPOINT %s",
+		ReleaseSavepoint:    "RELEASE SAVEPOINT %s",
+		RollbackToSavepoint: "ROLLBACK TO SAVEPOINT %s",
+	}
+
+	// DialectSQLite - диалект SQLite
+	DialectSQLite = Dialect{
+		Savepoint:           "SAVEPOINT %s",
+		ReleaseSavepoint:    "RELEASE %s",
+		RollbackToSavepoint: "ROLLBACK TO %s",
+	}
+)
+
+// WithDialect задает SQL-диалект для точек сохранения вложенных Do
+func WithDialect(d Dialect) Option {
+	return func(s *settings) {
+		s.dialect = d
+	}
+}
+
+// doNested выполняет f внутри уже открытой транзакции, ограничивая ее
+// точкой сохранения: при ошибке f откатывается только работа f,
+// а внешняя транзакция продолжается.
+// Транзакция драйвера должна реализовывать StatementExecer
+func (trm *Manager[T]) doNested(ctx context.Context, t *transaction, s settings, f func(ctx context.Context) (any, error)) (any, error) {
+	execer, ok := t.tx.(StatementExecer)
+	if !ok {
+		return nil, ErrSavepointsUnsupported
+	}
+
+	t.savepoints++
+	name := fmt.Sprintf("sp_%d", t.savepoints)
+	// Пометку на откат, поставленную присоединившимися вызовами внутри f,
+	// снимает откат к точке сохранения: их работа отменяется вместе с работой f
+	rollbackOnly := t.rollbackOnly
+
+	if err := execer.ExecStatement(ctx, fmt.Sprintf(s.dialect.Savepoint, name)); err != nil {
+		return nil, fmt.Errorf("cannot create savepoint %s: %w", name, err)
+	}
+
+	mark := t.hooks.mark()
+
+	res, err := f(trm.withTransaction(ctx, t))
+
+	// Точка сохранения завершается и после отмены ctx вложенного вызова:
+	// внешний f может обработать ошибку и закоммитить транзакцию,
+	// и работа вложенного вызова не должна в нее попасть
+	endCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		// При ошибке откатываемся к точке сохранения
+		if rbErr := execer.ExecStatement(endCtx, fmt.Sprintf(s.dialect.RollbackToSavepoint, name)); rbErr != nil {
+			err = fmt.Errorf("cannot rollback to savepoint %s with err: %s prev error: %w", name, rbErr, err)
+		} else {
+			t.rollbackOnly = rollbackOnly
+		}
+		// Работа f отменена: ее хуки коммита не нужны, а хуки отката выполняем сразу
+		runHooks(ctx, s, t.hooks.rollbackTo(mark))
+		return nil, err
+	}
+
+	if err := execer.ExecStatement(endCtx, fmt.Sprintf(s.dialect.ReleaseSavepoint, name)); err != nil {
+		return nil, fmt.Errorf("cannot release savepoint %s with error: %w", name, err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,157 @@
+package gotrxmanager
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectExec ожидает выполнение запроса query без аргументов
+func expectExec(mock sqlmock.Sqlmock, query string) *sqlmock.ExpectedExec {
+	return mock.ExpectExec(regexp.QuoteMeta(query)).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestTransactionManager_Do_NestedReleasesSavepoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "RELEASE SAVEPOINT sp_1")
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+
+	result, err := trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		outerTx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+
+		return trm.Do(ctx, func(ctx context.Context) (any, error) {
+			// Вложенный вызов работает в той же транзакции
+			innerTx, err := TxFromContext(ctx, trm)
+			require.NoError(t, err)
+			assert.Same(t, outerTx, innerTx)
+
+			return "inner", nil
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "inner", result)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_NestedErrorRollsBackToSavepoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "ROLLBACK TO SAVEPOINT sp_1")
+	expectExec(mock, "SAVEPOINT sp_2")
+	expectExec(mock, "RELEASE SAVEPOINT sp_2")
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+	innerErr := errors.New("inner failed")
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		// Ошибка внутреннего вызова не прерывает внешнюю транзакцию
+		_, err := trm.Do(ctx, func(ctx context.Context) (any, error) {
+			return nil, innerErr
+		})
+		assert.ErrorIs(t, err, innerErr)
+
+		return trm.Do(ctx, func(ctx context.Context) (any, error) {
+			return nil, nil
+		})
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_NestedCancelledRollsBackToSavepoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "INSERT INTO orders")
+	expectExec(mock, "ROLLBACK TO SAVEPOINT sp_1")
+	mock.ExpectCommit()
+
+	trm := NewTransactionManager(db)
+
+	err = DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		// Вложенный вызов отменяется собственным контекстом, внешний обрабатывает ошибку
+		innerCtx, cancel := context.WithCancel(ctx)
+		err := DoVoid(innerCtx, trm, func(ctx context.Context) error {
+			tx, err := TxFromContext(ctx, trm)
+			require.NoError(t, err)
+			_, err = tx.ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)")
+			require.NoError(t, err)
+
+			cancel()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_NestedSavepointError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SAVEPOINT sp_1")).WillReturnError(errors.New("savepoint error"))
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db)
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return trm.Do(ctx, func(ctx context.Context) (any, error) {
+			t.Fatal("function should not be called")
+			return nil, nil
+		})
+	})
+
+	assert.EqualError(t, err, "cannot create savepoint sp_1: savepoint error")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTransactionManager_Do_NestedDialect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	expectExec(mock, "SAVEPOINT sp_1")
+	expectExec(mock, "ROLLBACK TO sp_1")
+	mock.ExpectRollback()
+
+	trm := NewTransactionManager(db, WithDialect(DialectSQLite))
+	innerErr := errors.New("inner failed")
+
+	_, err = trm.Do(context.Background(), func(ctx context.Context) (any, error) {
+		return trm.Do(ctx, func(ctx context.Context) (any, error) {
+			return nil, innerErr
+		})
+	})
+
+	assert.ErrorIs(t, err, innerErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,238 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// SQLTx - адаптер *sql.Tx к интерфейсу Tx.
+// Методы выполнения запросов *sql.Tx доступны через встраивание.
+type SQLTx struct {
+	*sql.Tx
+	statements atomic.Int64 // Количество выполненных запросов
+
+	// Завершение точки сохранения, если транзакция открыта менеджером
+	// из NewSavepointTransactionManager; nil для обычной транзакции
+	end func(ctx context.Context, commit bool) error
+
+	started []uintptr       // Стек открытия транзакции
+	done    atomic.Bool     // Do, открывший транзакцию, завершен
+	report  func(err error) // Обработчик использования после завершения (WithLeakDetection)
+}
+
+// Commit коммитит транзакцию
+func (t *SQLTx) Commit(ctx context.Context) error {
+	if t.end != nil {
+		return t.end(ctx, true)
+	}
+	return t.Tx.Commit()
+}
+
+// Rollback откатывает транзакцию
+func (t *SQLTx) Rollback(ctx context.Context) error {
+	if t.end != nil {
+		return t.end(ctx, false)
+	}
+	return t.Tx.Rollback()
+}
+
+// ExecStatement выполняет служебную SQL-команду в транзакции.
+// Такие команды не учитываются в Statements
+func (t *SQLTx) ExecStatement(ctx context.Context, query string) error {
+	_, err := t.Tx.ExecContext(ctx, query)
+	return err
+}
+
+// Statements возвращает количество запросов, выполненных в транзакции
+func (t *SQLTx) Statements() int64 {
+	return t.statements.Load()
+}
+
+// ExecContext выполняет запрос без возврата строк.
+// После завершения Do возвращает *TxCompletedError
+func (t *SQLTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := t.checkCompleted(); err != nil {
+		return nil, err
+	}
+	t.statements.Add(1)
+	return t.Tx.ExecContext(ctx, query, args...)
+}
+
+// QueryContext выполняет запрос, возвращающий строки.
+// После завершения Do возвращает *TxCompletedError
+func (t *SQLTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if err := t.checkCompleted(); err != nil {
+		return nil, err
+	}
+	t.statements.Add(1)
+	return t.Tx.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext выполняет запрос, возвращающий не более одной строки.
+// После завершения Do запрос не выполняется, а Scan возвращает context.Canceled
+// (см. TxCompletedError)
+func (t *SQLTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if t.checkCompleted() != nil {
+		return t.Tx.QueryRowContext(canceledContext(ctx), query, args...)
+	}
+	t.statements.Add(1)
+	return t.Tx.QueryRowContext(ctx, query, args...)
+}
+
+// PrepareContext подготавливает запрос в транзакции.
+// После завершения Do возвращает *TxCompletedError
+func (t *SQLTx) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	if err := t.checkCompleted(); err != nil {
+		return nil, err
+	}
+	t.statements.Add(1)
+	return t.Tx.PrepareContext(ctx, query)
+}
+
+// StmtContext возвращает подготовленный вне транзакции запрос, привязанный к ней.
+// После завершения Do запрос не привязывается, а его методы возвращают
+// context.Canceled (см. TxCompletedError)
+func (t *SQLTx) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if t.checkCompleted() != nil {
+		return t.Tx.StmtContext(canceledContext(ctx), stmt)
+	}
+	t.statements.Add(1)
+	return t.Tx.StmtContext(ctx, stmt)
+}
+
+// Exec выполняет запрос без возврата строк
+func (t *SQLTx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+// Query выполняет запрос, возвращающий строки
+func (t *SQLTx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow выполняет запрос, возвращающий не более одной строки
+func (t *SQLTx) QueryRow(query string, args ...any) *sql.Row {
+	return t.QueryRowContext(context.Background(), query, args...)
+}
+
+// Prepare подготавливает запрос в транзакции
+func (t *SQLTx) Prepare(query string) (*sql.Stmt, error) {
+	return t.PrepareContext(context.Background(), query)
+}
+
+// Stmt возвращает подготовленный вне транзакции запрос, привязанный к ней
+func (t *SQLTx) Stmt(stmt *sql.Stmt) *sql.Stmt {
+	return t.StmtContext(context.Background(), stmt)
+}
+
+// canceledContext возвращает отмененный контекст: *sql.Row и *sql.Stmt
+// не могут содержать произвольную ошибку, а запрос с таким контекстом
+// database/sql не выполняет
+func canceledContext(ctx context.Context) context.Context {
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	return canceled
+}
+
+// track запоминает стек открытия транзакции и обработчик утечек
+func (t *SQLTx) track(started []uintptr, report func(err error)) {
+	t.started = started
+	t.report = report
+}
+
+// complete помечает транзакцию завершенной
+func (t *SQLTx) complete() {
+	t.done.Store(true)
+}
+
+// checkCompleted возвращает *TxCompletedError, если Do уже завершен
+func (t *SQLTx) checkCompleted() error {
+	if !t.done.Load() {
+		return nil
+	}
+
+	err := &TxCompletedError{Stack: formatStack(t.started)}
+	if t.report != nil {
+		t.report(err)
+	}
+	return err
+}
+
+// sqlBeginner - адаптер *sql.DB к интерфейсу Beginner
+type sqlBeginner struct {
+	db       *sql.DB     // Основная БД
+	replicas *ReplicaSet // Реплики для транзакций только для чтения
+
+	outer      *sql.Tx      // Внешняя транзакция NewSavepointTransactionManager
+	dialect    Dialect      // SQL точек сохранения во внешней транзакции
+	savepoints atomic.Int64 // Счетчик имен точек сохранения во внешней транзакции
+}
+
+// Begin открывает транзакцию database/sql.
+// Транзакции только для чтения по возможности открываются на реплике
+func (b *sqlBeginner) Begin(ctx context.Context, opts sql.TxOptions) (*SQLTx, error) {
+	if b.outer != nil {
+		return b.beginSavepoint(ctx)
+	}
+	if opts.ReadOnly {
+		if tx, ok := b.replicas.beginReadOnly(ctx, opts); ok {
+			return &SQLTx{Tx: tx}, nil
+		}
+	}
+
+	tx, err := b.db.BeginTx(ctx, &opts)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLTx{Tx: tx}, nil
+}
+
+// beginSavepoint открывает "транзакцию" как точку сохранения во внешней транзакции.
+// Коммит освобождает точку сохранения, откат - откатывает к ней
+func (b *sqlBeginner) beginSavepoint(ctx context.Context) (*SQLTx, error) {
+	name := fmt.Sprintf("trx_%d", b.savepoints.Add(1))
+	if _, err := b.outer.ExecContext(ctx, fmt.Sprintf(b.dialect.Savepoint, name)); err != nil {
+		return nil, fmt.Errorf("cannot create savepoint %s: %w", name, err)
+	}
+
+	end := func(ctx context.Context, commit bool) error {
+		query := b.dialect.RollbackToSavepoint
+		if commit {
+			query = b.dialect.ReleaseSavepoint
+		}
+		// Внешняя транзакция не зависит от ctx Do: откат к точке сохранения
+		// нужен и после таймаута или отмены, иначе изменения неудачного Do
+		// останутся видны остальным запросам внешней транзакции
+		_, err := b.outer.ExecContext(context.WithoutCancel(ctx), fmt.Sprintf(query, name))
+		return err
+	}
+	return &SQLTx{Tx: b.outer, end: end}, nil
+}
+
+// conn возвращает соединение для запросов вне транзакции менеджера
+func (b *sqlBeginner) conn() conn {
+	if b.outer != nil {
+		return b.outer
+	}
+	return b.db
+}
+
+// readConn возвращает соединение для чтения вне транзакции менеджера
+func (b *sqlBeginner) readConn(ctx context.Context) conn {
+	if b.outer != nil {
+		return b.outer
+	}
+	return b.replicas.readDB(ctx, b.db)
+}
+
+// sqlBeginnerOf возвращает адаптер БД менеджера. Компонентам, которым нужна
+// сама БД, а не только транзакции, подходит лишь менеджер из NewTransactionManager
+func sqlBeginnerOf(trm *TransactionManager, component string) *sqlBeginner {
+	b, ok := trm.beginner.(*sqlBeginner)
+	if !ok {
+		panic(fmt.Sprintf("gotrxmanager: %s requires a manager created by NewTransactionManager", component))
+	}
+	return b
+}
@@ -1,8 +1,66 @@
 package gotrxmanager
 
-import "database/sql"
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
-// TransactionManager - менеджер транзакций, оборачивающий соединение с БД
-type TransactionManager struct {
-	db *sql.DB
+// Manager - менеджер транзакций драйвера, транзакции которого имеют тип T
+type Manager[T Tx] struct {
+	key      *trxManagerKey // Ключ транзакции менеджера в контексте
+	beginner Beginner[T]
+	defaults settings // Настройки транзакций по умолчанию
+	metrics  metrics  // Счетчики коммитов и откатов
+}
+
+// TransactionManager - менеджер транзакций, оборачивающий соединение с БД database/sql
+type TransactionManager = Manager[*SQLTx]
+
+// transaction - открытая менеджером транзакция, хранящаяся в контексте
+type transaction struct {
+	tx         Tx
+	savepoints int // Счетчик для имен точек сохранения
+
+	// Транзакция помечена на откат присоединившимся вызовом (PropagationRequired)
+	rollbackOnly bool
+
+	hooks hooks // Хуки AfterCommit/AfterRollback
+}
+
+// Coordinator - координатор транзакций на нескольких БД.
+// Коммитит транзакции ресурсов по порядку регистрации и при сбое
+// выполняет компенсации уже закоммиченных ресурсов (best effort, не 2PC)
+type Coordinator struct {
+	resources []coordinatedResource
+	twoPhase  bool // Подготавливать транзакции через PREPARE TRANSACTION
+}
+
+// coordinatedResource - БД, зарегистрированная в координаторе
+type coordinatedResource struct {
+	name string
+	trm  *TransactionManager
+	db   *sql.DB
+}
+
+// ReplicaSet - набор реплик для чтения с проверкой их доступности
+type ReplicaSet struct {
+	replicas []*replica
+	balancer Balancer
+	timeout  time.Duration // Таймаут проверки одной реплики
+	next     atomic.Uint64 // Счетчик для выбора по очереди
+	// Задан HealthCheckInterval: реплика, не открывшая транзакцию,
+	// исключается до следующей фоновой проверки
+	backgroundCheck bool
+
+	stop      chan struct{} // Остановка фоновой проверки
+	done      chan struct{} // Фоновая проверка завершена
+	closeOnce sync.Once
+}
+
+// replica - реплика и ее доступность по последней проверке
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
 }
@@ -0,0 +1,44 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Tx - транзакция произвольного драйвера БД.
+// Менеджеру нужно только завершать транзакцию, запросы выполняются
+// через методы конкретного типа драйвера.
+type Tx interface {
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// Beginner открывает транзакции драйвера с заданными настройками
+type Beginner[T Tx] interface {
+	Begin(ctx context.Context, opts sql.TxOptions) (T, error)
+}
+
+// BeginnerFunc - адаптер функции к интерфейсу Beginner
+type BeginnerFunc[T Tx] func(ctx context.Context, opts sql.TxOptions) (T, error)
+
+// Begin вызывает f(ctx, opts)
+func (f BeginnerFunc[T]) Begin(ctx context.Context, opts sql.TxOptions) (T, error) {
+	return f(ctx, opts)
+}
+
+// StatementExecer реализуется транзакциями, способными выполнить SQL-команду
+// без параметров и результата. Нужен для точек сохранения вложенных Do.
+type StatementExecer interface {
+	ExecStatement(ctx context.Context, query string) error
+}
+
+// StatementCounter реализуется транзакциями, считающими выполненные запросы.
+// Количество запросов попадает в отчет о медленной транзакции.
+type StatementCounter interface {
+	Statements() int64
+}
+
+// ErrSavepointsUnsupported возвращается вложенным Do (PropagationNested),
+// если транзакция драйвера не реализует StatementExecer
+var ErrSavepointsUnsupported = errors.New("transaction does not support savepoints")
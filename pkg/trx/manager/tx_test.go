@@ -0,0 +1,104 @@
+package gotrxmanager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTx - транзакция драйвера, не связанного с database/sql
+type fakeTx struct {
+	opts       sql.TxOptions
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+// fakeBeginner запоминает открытые транзакции
+func fakeBeginner(txs *[]*fakeTx) BeginnerFunc[*fakeTx] {
+	return func(ctx context.Context, opts sql.TxOptions) (*fakeTx, error) {
+		tx := &fakeTx{opts: opts}
+		*txs = append(*txs, tx)
+		return tx, nil
+	}
+}
+
+func TestManager_CustomDriverCommit(t *testing.T) {
+	var txs []*fakeTx
+	trm := NewManager(fakeBeginner(&txs), WithReadOnly())
+	committed := false
+
+	result, err := Do(context.Background(), trm, func(ctx context.Context) (string, error) {
+		tx, err := TxFromContext(ctx, trm)
+		require.NoError(t, err)
+		assert.True(t, tx.opts.ReadOnly)
+
+		require.NoError(t, AfterCommit(ctx, func(ctx context.Context) { committed = true }))
+		return "ok", nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", result)
+	require.Len(t, txs, 1)
+	assert.True(t, txs[0].committed)
+	assert.False(t, txs[0].rolledBack)
+	assert.True(t, committed)
+}
+
+func TestManager_CustomDriverRollback(t *testing.T) {
+	var txs []*fakeTx
+	trm := NewManager(fakeBeginner(&txs))
+	rolledBack := false
+
+	err := DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		require.NoError(t, AfterRollback(ctx, func(ctx context.Context) { rolledBack = true }))
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	require.Len(t, txs, 1)
+	assert.True(t, txs[0].rolledBack)
+	assert.False(t, txs[0].committed)
+	assert.True(t, rolledBack)
+}
+
+func TestManager_CustomDriverBeginError(t *testing.T) {
+	beginErr := errors.New("connection refused")
+	trm := NewManager(BeginnerFunc[*fakeTx](func(ctx context.Context, opts sql.TxOptions) (*fakeTx, error) {
+		return nil, beginErr
+	}))
+
+	err := DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		t.Fatal("f must not be called")
+		return nil
+	})
+
+	assert.ErrorIs(t, err, beginErr)
+}
+
+func TestManager_NestedWithoutSavepoints(t *testing.T) {
+	var txs []*fakeTx
+	trm := NewManager(fakeBeginner(&txs))
+
+	err := DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		// fakeTx не реализует StatementExecer
+		return DoVoid(ctx, trm, func(ctx context.Context) error { return nil })
+	})
+
+	assert.ErrorIs(t, err, ErrSavepointsUnsupported)
+	require.Len(t, txs, 1)
+	assert.True(t, txs[0].rolledBack)
+}
@@ -0,0 +1,136 @@
+// Package outbox реализует шаблон transactional outbox поверх gotrxmanager:
+// события записываются в таблицу outbox в той же транзакции, что и бизнес-данные,
+// а Relay в фоне доставляет их во внешнюю систему (брокер сообщений и т.п.).
+//
+// Схема таблицы для PostgreSQL:
+//
+//	CREATE TABLE outbox (
+//		id         BIGSERIAL PRIMARY KEY,
+//		topic      TEXT        NOT NULL,
+//		payload    BYTEA       NOT NULL,
+//		attempts   INT         NOT NULL DEFAULT 0,
+//		last_error TEXT,
+//		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+//		sent_at    TIMESTAMPTZ
+//	);
+//	CREATE INDEX outbox_unsent_idx ON outbox (id) WHERE sent_at IS NULL;
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+// Message - событие из таблицы outbox
+type Message struct {
+	ID        int64
+	Topic     string
+	Payload   []byte
+	Attempts  int       // Число неудачных попыток доставки
+	CreatedAt time.Time // Время записи в outbox
+}
+
+// Dialect - шаблоны SQL для работы с таблицей outbox.
+// Каждый шаблон содержит один %s, куда подставляется имя таблицы.
+type Dialect struct {
+	Insert     string // Параметры: topic, payload
+	Fetch      string // Параметры: максимум попыток, размер пачки
+	MarkSent   string // Параметры: sent_at, id
+	MarkFailed string // Параметры: last_error, id
+	Cleanup    string // Параметры: граница sent_at
+}
+
+var (
+	// DialectPostgres - диалект PostgreSQL (используется по умолчанию)
+	DialectPostgres = Dialect{
+		Insert:     "INSERT INTO %s (topic, payload) VALUES ($1, $2)",
+		Fetch:      "SELECT id, topic, payload, attempts, created_at FROM %s WHERE sent_at IS NULL AND attempts < $1 ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED",
+		MarkSent:   "UPDATE %s SET sent_at = $1 WHERE id = $2",
+		MarkFailed: "UPDATE %s SET attempts = attempts + 1, last_error = $1 WHERE id = $2",
+		Cleanup:    "DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < $1",
+	}
+
+	// DialectMySQL - диалект MySQL 8 и новее
+	DialectMySQL = Dialect{
+		Insert:     "INSERT INTO %s (topic, payload) VALUES (?, ?)",
+		Fetch:      "SELECT id, topic, payload, attempts, created_at FROM %s WHERE sent_at IS NULL AND attempts < ? ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED",
+		MarkSent:   "UPDATE %s SET sent_at = ? WHERE id = ?",
+		MarkFailed: "UPDATE %s SET attempts = attempts + 1, last_error = ? WHERE id = ?",
+		Cleanup:    "DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < ?",
+	}
+
+	// DialectSQLite - диалект SQLite. Блокировки строк в SQLite нет,
+	// поэтому запускать больше одного Relay на таблицу нельзя
+	DialectSQLite = Dialect{
+		Insert:     "INSERT INTO %s (topic, payload) VALUES (?, ?)",
+		Fetch:      "SELECT id, topic, payload, attempts, created_at FROM %s WHERE sent_at IS NULL AND attempts < ? ORDER BY id LIMIT ?",
+		MarkSent:   "UPDATE %s SET sent_at = ? WHERE id = ?",
+		MarkFailed: "UPDATE %s SET attempts = attempts + 1, last_error = ? WHERE id = ?",
+		Cleanup:    "DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < ?",
+	}
+)
+
+// defaultTable - имя таблицы outbox по умолчанию
+const defaultTable = "outbox"
+
+// Config - настройки outbox
+type Config struct {
+	Table   string   // Имя таблицы, по умолчанию "outbox"
+	Dialect *Dialect // Диалект SQL, по умолчанию DialectPostgres
+}
+
+// Outbox записывает события в таблицу outbox в транзакции менеджера
+type Outbox struct {
+	trm     *gotrxmanager.TransactionManager
+	queries queries
+}
+
+// queries - запросы диалекта с подставленным именем таблицы
+type queries struct {
+	insert     string
+	fetch      string
+	markSent   string
+	markFailed string
+	cleanup    string
+}
+
+// New создает Outbox поверх менеджера транзакций
+func New(trm *gotrxmanager.TransactionManager, config Config) *Outbox {
+	table := config.Table
+	if table == "" {
+		table = defaultTable
+	}
+	dialect := DialectPostgres
+	if config.Dialect != nil {
+		dialect = *config.Dialect
+	}
+
+	return &Outbox{
+		trm: trm,
+		queries: queries{
+			insert:     fmt.Sprintf(dialect.Insert, table),
+			fetch:      fmt.Sprintf(dialect.Fetch, table),
+			markSent:   fmt.Sprintf(dialect.MarkSent, table),
+			markFailed: fmt.Sprintf(dialect.MarkFailed, table),
+			cleanup:    fmt.Sprintf(dialect.Cleanup, table),
+		},
+	}
+}
+
+// Enqueue записывает событие в outbox в транзакции из контекста.
+// Событие будет доставлено, только если эта транзакция закоммитится.
+// Вне транзакции менеджера возвращает gotrxmanager.ErrNoTransaction.
+func (o *Outbox) Enqueue(ctx context.Context, topic string, payload []byte) error {
+	tx, err := gotrxmanager.TxFromContext(ctx, o.trm)
+	if err != nil {
+		return gotrxmanager.ErrNoTransaction
+	}
+
+	if _, err := tx.ExecContext(ctx, o.queries.insert, topic, payload); err != nil {
+		return fmt.Errorf("cannot enqueue outbox message: %w", err)
+	}
+	return nil
+}
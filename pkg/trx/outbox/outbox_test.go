@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+func TestOutbox_EnqueueInTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO orders (id) VALUES ($1)")).WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO outbox (topic, payload) VALUES ($1, $2)")).
+		WithArgs("order.created", []byte(`{"id":1}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	trm := gotrxmanager.NewTransactionManager(db)
+	o := New(trm, Config{})
+
+	err = gotrxmanager.DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		_, err := gotrxmanager.NewQuerier(trm).ExecContext(ctx, "INSERT INTO orders (id) VALUES ($1)", 1)
+		require.NoError(t, err)
+		return o.Enqueue(ctx, "order.created", []byte(`{"id":1}`))
+	})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_EnqueueRolledBackWithTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO events").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	sqlite := DialectSQLite
+	trm := gotrxmanager.NewTransactionManager(db)
+	o := New(trm, Config{Table: "events", Dialect: &sqlite})
+
+	err = gotrxmanager.DoVoid(context.Background(), trm, func(ctx context.Context) error {
+		require.NoError(t, o.Enqueue(ctx, "order.created", nil))
+		return assert.AnError
+	})
+
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_EnqueueRequiresTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	o := New(gotrxmanager.NewTransactionManager(db), Config{})
+
+	err = o.Enqueue(context.Background(), "order.created", nil)
+
+	assert.ErrorIs(t, err, gotrxmanager.ErrNoTransaction)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,189 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go-utils/pkg/clock"
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+// Значения RelayConfig по умолчанию
+const (
+	defaultPollInterval    = time.Second
+	defaultBatchSize       = 100
+	defaultMaxAttempts     = 10
+	defaultCleanupInterval = time.Hour
+)
+
+// Publisher доставляет событие во внешнюю систему.
+// Доставка выполняется по принципу at-least-once: если после успешного Publish
+// не удалось закоммитить отметку об отправке, событие будет отправлено повторно.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+// PublisherFunc - адаптер функции к интерфейсу Publisher
+type PublisherFunc func(ctx context.Context, msg Message) error
+
+// Publish вызывает f(ctx, msg)
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+// RelayConfig - настройки доставки событий
+type RelayConfig struct {
+	PollInterval    time.Duration // Период опроса таблицы, по умолчанию 1s
+	BatchSize       int           // Сообщений за один опрос, по умолчанию 100
+	MaxAttempts     int           // Попыток доставки сообщения, по умолчанию 10
+	Retention       time.Duration // Сколько хранить отправленные сообщения, 0 - не удалять
+	CleanupInterval time.Duration // Период удаления отправленных сообщений, по умолчанию 1h
+	Clock           clock.Clock   // Источник времени, по умолчанию реальные часы
+	Logger          *slog.Logger  // Логгер ошибок, по умолчанию slog.Default()
+}
+
+// Relay периодически забирает неотправленные события из outbox и передает их Publisher.
+// Сообщения блокируются через FOR UPDATE SKIP LOCKED, поэтому несколько Relay
+// могут работать с одной таблицей параллельно (кроме DialectSQLite).
+// Неудачно доставленное сообщение повторяется при следующих опросах, пока
+// не исчерпано MaxAttempts; после этого оно остается в таблице для разбора.
+// Порядок доставки сохраняется только при отсутствии ошибок.
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	config    RelayConfig
+	clock     clock.Clock
+	logger    *slog.Logger
+
+	lastCleanup time.Time
+}
+
+// NewRelay создает Relay для outbox
+func NewRelay(o *Outbox, publisher Publisher, config RelayConfig) *Relay {
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.CleanupInterval <= 0 {
+		config.CleanupInterval = defaultCleanupInterval
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	clk := clock.OrDefault(config.Clock)
+	return &Relay{
+		outbox:      o,
+		publisher:   publisher,
+		config:      config,
+		clock:       clk,
+		logger:      logger,
+		lastCleanup: clk.Now(),
+	}
+}
+
+// Run опрашивает outbox до отмены ctx и возвращает ошибку контекста.
+// Ошибки отдельных опросов пишутся в лог и не останавливают Relay.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := r.clock.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		r.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+// poll выполняет один опрос и, если пора, очистку
+func (r *Relay) poll(ctx context.Context) {
+	if _, err := r.ProcessBatch(ctx); err != nil && ctx.Err() == nil {
+		r.logger.ErrorContext(ctx, "outbox relay failed", "error", err)
+	}
+
+	if r.config.Retention <= 0 || r.clock.Now().Sub(r.lastCleanup) < r.config.CleanupInterval {
+		return
+	}
+	r.lastCleanup = r.clock.Now()
+	if _, err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+		r.logger.ErrorContext(ctx, "outbox cleanup failed", "error", err)
+	}
+}
+
+// ProcessBatch забирает до BatchSize неотправленных сообщений в отдельной
+// транзакции, передает их Publisher и отмечает результат доставки.
+// Возвращает количество доставленных сообщений.
+func (r *Relay) ProcessBatch(ctx context.Context) (int, error) {
+	return gotrxmanager.Do(ctx, r.outbox.trm, func(ctx context.Context) (int, error) {
+		tx, err := gotrxmanager.TxFromContext(ctx, r.outbox.trm)
+		if err != nil {
+			return 0, err
+		}
+
+		msgs, err := r.fetch(ctx, tx)
+		if err != nil {
+			return 0, err
+		}
+
+		sent := 0
+		for _, msg := range msgs {
+			if pubErr := r.publisher.Publish(ctx, msg); pubErr != nil {
+				if _, err := tx.ExecContext(ctx, r.outbox.queries.markFailed, pubErr.Error(), msg.ID); err != nil {
+					return 0, fmt.Errorf("cannot mark outbox message %d as failed: %w", msg.ID, err)
+				}
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, r.outbox.queries.markSent, r.clock.Now(), msg.ID); err != nil {
+				return 0, fmt.Errorf("cannot mark outbox message %d as sent: %w", msg.ID, err)
+			}
+			sent++
+		}
+		return sent, nil
+	}, gotrxmanager.WithPropagation(gotrxmanager.PropagationRequiresNew))
+}
+
+// fetch блокирует и читает пачку неотправленных сообщений
+func (r *Relay) fetch(ctx context.Context, tx *gotrxmanager.SQLTx) ([]Message, error) {
+	rows, err := tx.QueryContext(ctx, r.outbox.queries.fetch, r.config.MaxAttempts, r.config.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var msg Message
+		if err := rows.Scan(&msg.ID, &msg.Topic, &msg.Payload, &msg.Attempts, &msg.CreatedAt); err != nil {
+			return nil, fmt.Errorf("cannot scan outbox message: %w", err)
+		}
+		msgs = append(msgs, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot fetch outbox messages: %w", err)
+	}
+	return msgs, nil
+}
+
+// Cleanup удаляет сообщения, отправленные раньше, чем Retention назад.
+// Возвращает количество удаленных сообщений.
+func (r *Relay) Cleanup(ctx context.Context) (int64, error) {
+	before := r.clock.Now().Add(-r.config.Retention)
+	res, err := gotrxmanager.NewQuerier(r.outbox.trm).ExecContext(ctx, r.outbox.queries.cleanup, before)
+	if err != nil {
+		return 0, fmt.Errorf("cannot clean up outbox: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,148 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go-utils/pkg/clock"
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+var (
+	fetchQuery      = regexp.QuoteMeta("SELECT id, topic, payload, attempts, created_at FROM outbox WHERE sent_at IS NULL AND attempts < $1 ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED")
+	markSentQuery   = regexp.QuoteMeta("UPDATE outbox SET sent_at = $1 WHERE id = $2")
+	markFailedQuery = regexp.QuoteMeta("UPDATE outbox SET attempts = attempts + 1, last_error = $1 WHERE id = $2")
+	cleanupQuery    = regexp.QuoteMeta("DELETE FROM outbox WHERE sent_at IS NOT NULL AND sent_at < $1")
+)
+
+// outboxRows возвращает строки таблицы outbox для сообщений с указанными id
+func outboxRows(ids ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "topic", "payload", "attempts", "created_at"})
+	for _, id := range ids {
+		rows.AddRow(id, "order.created", []byte(`{}`), 0, time.Unix(0, 0))
+	}
+	return rows
+}
+
+func TestRelay_ProcessBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	clk := clock.NewFake(time.Unix(1000, 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery(fetchQuery).WithArgs(3, 10).WillReturnRows(outboxRows(1, 2))
+	mock.ExpectExec(markSentQuery).WithArgs(clk.Now(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(markFailedQuery).WithArgs("broker unavailable", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	var published []int64
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error {
+		if msg.ID == 2 {
+			return errors.New("broker unavailable")
+		}
+		published = append(published, msg.ID)
+		return nil
+	})
+	relay := NewRelay(New(gotrxmanager.NewTransactionManager(db), Config{}), publisher, RelayConfig{
+		BatchSize:   10,
+		MaxAttempts: 3,
+		Clock:       clk,
+	})
+
+	sent, err := relay.ProcessBatch(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []int64{1}, published)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_ProcessBatchRollsBackOnMarkError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(fetchQuery).WillReturnRows(outboxRows(1))
+	mock.ExpectExec(markSentQuery).WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error { return nil })
+	relay := NewRelay(New(gotrxmanager.NewTransactionManager(db), Config{}), publisher, RelayConfig{})
+
+	sent, err := relay.ProcessBatch(context.Background())
+
+	assert.EqualError(t, err, "cannot mark outbox message 1 as sent: connection reset")
+	assert.Zero(t, sent)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_Cleanup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	clk := clock.NewFake(time.Unix(100000, 0))
+	mock.ExpectExec(cleanupQuery).WithArgs(clk.Now().Add(-24 * time.Hour)).WillReturnResult(sqlmock.NewResult(0, 5))
+
+	relay := NewRelay(New(gotrxmanager.NewTransactionManager(db), Config{}), nil, RelayConfig{
+		Retention: 24 * time.Hour,
+		Clock:     clk,
+	})
+
+	deleted, err := relay.Cleanup(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelay_Run(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Первый опрос сразу после запуска, второй - по тику с очисткой
+	mock.ExpectBegin()
+	mock.ExpectQuery(fetchQuery).WillReturnRows(outboxRows())
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(fetchQuery).WillReturnRows(outboxRows(1))
+	mock.ExpectExec(markSentQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(cleanupQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	clk := clock.NewFake(time.Now())
+	published := make(chan Message, 1)
+	publisher := PublisherFunc(func(ctx context.Context, msg Message) error {
+		published <- msg
+		return nil
+	})
+	relay := NewRelay(New(gotrxmanager.NewTransactionManager(db), Config{}), publisher, RelayConfig{
+		PollInterval:    time.Second,
+		Retention:       time.Hour,
+		CleanupInterval: time.Second,
+		Clock:           clk,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- relay.Run(ctx) }()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	msg := <-published
+	assert.Equal(t, int64(1), msg.ID)
+
+	assert.Eventually(t, func() bool { return mock.ExpectationsWereMet() == nil }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
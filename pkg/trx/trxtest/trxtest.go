@@ -0,0 +1,45 @@
+// Package trxtest помогает писать интеграционные тесты репозиториев
+// на реальной БД без очистки таблиц между тестами.
+//
+//	func TestOrderRepository(t *testing.T) {
+//		trm := trxtest.New(t, db)
+//		repo := NewOrderRepository(trm)
+//		...
+//	}
+//
+// Все изменения теста выполняются в одной транзакции, которая
+// откатывается по завершении теста, поэтому каждый тест начинает с чистой БД.
+package trxtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+// New открывает в db транзакцию теста и возвращает менеджер, работающий внутри нее.
+// Каждый Do менеджера выполняется в точке сохранения этой транзакции:
+// коммит Do виден следующим запросам теста, а ошибка откатывает только его работу.
+// Транзакция откатывается в t.Cleanup.
+//
+// Опции передаются менеджеру; точки сохранения создаются по WithDialect
+// (по умолчанию PostgreSQL). Тест не должен вызывать Do из нескольких горутин
+// одновременно, так как все запросы идут через одно соединение.
+func New(t testing.TB, db *sql.DB, opts ...gotrxmanager.Option) *gotrxmanager.TransactionManager {
+	t.Helper()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("trxtest: cannot begin test transaction: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			t.Errorf("trxtest: cannot rollback test transaction: %v", err)
+		}
+	})
+
+	return gotrxmanager.NewSavepointTransactionManager(tx, opts...)
+}
@@ -0,0 +1,91 @@
+package trxtest
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gotrxmanager "go-utils/pkg/trx/manager"
+)
+
+func TestNew_RollsBackOnCleanup(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SAVEPOINT trx_1")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO orders")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("RELEASE SAVEPOINT trx_1")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	t.Run("test", func(t *testing.T) {
+		trm := New(t, db)
+
+		err := gotrxmanager.DoVoid(context.Background(), trm, func(ctx context.Context) error {
+			_, err := gotrxmanager.NewQuerier(trm).ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)")
+			return err
+		})
+		require.NoError(t, err)
+
+		// До завершения теста транзакция открыта
+		assert.Error(t, mock.ExpectationsWereMet())
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNew_Dialect(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SAVEPOINT trx_1")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("ROLLBACK TO trx_1")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	t.Run("test", func(t *testing.T) {
+		trm := New(t, db, gotrxmanager.WithDialect(gotrxmanager.DialectSQLite))
+
+		err := gotrxmanager.DoVoid(context.Background(), trm, func(ctx context.Context) error {
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNew_TimeoutRollsBackToSavepoint(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SAVEPOINT trx_1")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO orders")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta("ROLLBACK TO SAVEPOINT trx_1")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	t.Run("test", func(t *testing.T) {
+		trm := New(t, db, gotrxmanager.WithTimeout(10*time.Millisecond))
+
+		// Изменения Do, отмененного по таймауту, не остаются в транзакции теста
+		err := gotrxmanager.DoVoid(context.Background(), trm, func(ctx context.Context) error {
+			if _, err := gotrxmanager.NewQuerier(trm).ExecContext(ctx, "INSERT INTO orders (id) VALUES (1)"); err != nil {
+				return err
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, gotrxmanager.ErrTransactionTimeout)
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}